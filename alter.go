@@ -0,0 +1,311 @@
+package dbf3
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (f *file) RenameField(oldName, newName string) error {
+	fldIdx, ok := f.fieldsIdx[oldName]
+	if !ok {
+		return errors.New("field not found")
+	}
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	if f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+
+	fld := f.fields[fldIdx]
+	dt, err := newFieldDescr(newName, fld.Type(), fld.descr.len, fld.descr.dec)
+	if err != nil {
+		return err
+	}
+	name := dt.nameString()
+	if name == oldName {
+		return nil
+	}
+	if _, exists := f.fieldsIdx[name]; exists {
+		return errors.New("field already exists")
+	}
+
+	f.renameField(fldIdx, name)
+	f.record(renameFieldEdit(oldName, name))
+	f.fireSchemaChange()
+	return nil
+}
+
+// renameField changes name of field with specified index
+func (f *file) renameField(fldIdx int, name string) {
+	// fields could be returned by Fields, so they are not changed
+	fld := *f.fields[fldIdx]
+	delete(f.fieldsIdx, fld.name)
+	fld.descr.name = [11]byte{}
+	copy(fld.descr.name[:], name)
+	fld.name = name
+	f.fields[fldIdx] = &fld
+	f.fieldsIdx[name] = fldIdx
+	f.header.updateChanged()
+}
+
+func (f *file) AlterField(name string, typ FieldType, length, dec byte) ([]Issue, error) {
+	fldIdx, ok := f.fieldsIdx[name]
+	if !ok {
+		return nil, errors.New("field not found")
+	}
+	if f.readOnly {
+		return nil, errors.New("read only file")
+	}
+	if f.disk != nil {
+		return nil, errors.New("not supported for disk-backed file")
+	}
+
+	old := f.fields[fldIdx]
+	dt, err := newFieldDescr(name, typ, length, dec)
+	if err != nil {
+		return nil, err
+	}
+	fld, err := newField(dt, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if f.RLen()-old.Len()+fld.Len() > math.MaxUint16 {
+		return nil, errors.New("exceeded max row length")
+	}
+
+	var found issues
+	column := make([]byte, f.Rows()*fld.Len())
+	buf := make([]byte, 1+fld.Len()) // deletion flag and value
+	for row := 0; row < f.Rows(); row++ {
+		offset := row*f.RLen() + old.offset
+		val, msg := convertValue(old.Type(), fld, string(f.data[offset:offset+old.Len()]))
+		if msg != "" {
+			found.add(row, fldIdx, msg)
+		}
+		fld.setValue(buf, val)
+		copy(column[row*fld.Len():], buf[1:])
+	}
+
+	oldColumn := f.column(fldIdx)
+	f.removeField(fldIdx)
+	if err := f.insertField(fldIdx, dt, column); err != nil {
+		if rerr := f.insertField(fldIdx, old.descr, oldColumn); rerr != nil {
+			return nil, errors.New(err.Error() + " (restore failed: " + rerr.Error() + ")")
+		}
+		return nil, err
+	}
+	f.record(alterFieldEdit(fldIdx, old.descr, oldColumn, dt, column))
+	f.fireSchemaChange()
+	return found, nil
+}
+
+// convertValue converts raw value of field of specified type into value
+// of another field. If value can't be converted completely,
+// message describes the problem
+func convertValue(from FieldType, to *field, raw string) (string, string) {
+	var s string
+	if from == Character {
+		// leading spaces are kept for character values
+		s = strings.TrimRight(raw, " ")
+	} else {
+		s = strings.TrimSpace(raw)
+	}
+	if strings.TrimSpace(s) == "" {
+		return "", ""
+	}
+
+	switch to.Type() {
+	case Character:
+		if len(s) > to.Len() {
+			return s[:to.Len()], "value " + strconv.Quote(s) + " truncated"
+		}
+		return s, ""
+	case Numeric:
+		s = strings.TrimSpace(s)
+		if from != Numeric && from != Character || !validNumeric(s) {
+			break
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			break
+		}
+		val := strconv.FormatFloat(n, 'f', int(to.Dec()), 64)
+		if len(val) > to.Len() {
+			return "", "value " + strconv.Quote(s) + " does not fit field length"
+		}
+		if rounded, _ := strconv.ParseFloat(val, 64); rounded != n {
+			return val, "value " + strconv.Quote(s) + " rounded"
+		}
+		return val, ""
+	case Date:
+		if from != Date && from != Character {
+			break
+		}
+		if _, err := time.ParseInLocation(dateLayout, s, time.Local); err == nil {
+			return s, ""
+		}
+	case Logical:
+		if from != Logical && from != Character {
+			break
+		}
+		switch s {
+		case "T", "t", "Y", "y":
+			return "T", ""
+		case "F", "f", "N", "n":
+			return "F", ""
+		case "?":
+			return "?", ""
+		}
+	}
+
+	return "", "cannot convert value " + strconv.Quote(s) + " to type " + string(rune(to.Type()))
+}
+
+// renameFieldEdit creates edit of field renaming
+func renameFieldEdit(oldName, newName string) edit {
+	rename := func(from, to string) func(f *file) error {
+		return func(f *file) error {
+			fldIdx, ok := f.fieldsIdx[from]
+			if !ok {
+				return errors.New("field not found")
+			}
+			f.renameField(fldIdx, to)
+			f.fireSchemaChange()
+			return nil
+		}
+	}
+	return edit{
+		undo: rename(newName, oldName),
+		redo: rename(oldName, newName),
+	}
+}
+
+// alterFieldEdit creates edit of field change. Values of field
+// before and after change are kept in columns
+func alterFieldEdit(pos int, before fieldDescr, beforeColumn []byte, after fieldDescr, afterColumn []byte) edit {
+	replace := func(dt fieldDescr, column []byte) func(f *file) error {
+		return func(f *file) error {
+			f.removeField(pos)
+			if err := f.insertField(pos, dt, column); err != nil {
+				return err
+			}
+			f.fireSchemaChange()
+			return nil
+		}
+	}
+	return edit{
+		undo: replace(before, beforeColumn),
+		redo: replace(after, afterColumn),
+		size: len(beforeColumn) + len(afterColumn),
+	}
+}
+
+func (f *file) InsertField(pos int, name string, typ FieldType, length, dec byte) error {
+	if pos < 0 || pos > len(f.fields) {
+		return errors.New("out of range")
+	}
+	dt, err := newFieldDescr(name, typ, length, dec)
+	if err != nil {
+		return err
+	}
+	if _, exists := f.fieldsIdx[dt.nameString()]; exists {
+		return errors.New("field already exists")
+	}
+
+	if err := f.insertField(pos, dt, nil); err != nil {
+		return err
+	}
+	f.record(addFieldEdit(pos, dt))
+	f.fireSchemaChange()
+	return nil
+}
+
+func (f *file) ReorderFields(names []string) error {
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	if f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+	if len(names) != len(f.fields) {
+		return errors.New("fields count differs")
+	}
+
+	order := make([]int, len(names))
+	used := make(map[int]bool, len(names))
+	for idx, name := range names {
+		fldIdx, ok := f.fieldsIdx[name]
+		if !ok {
+			return errors.New("field not found")
+		}
+		if used[fldIdx] {
+			return errors.New("duplicate field name")
+		}
+		used[fldIdx] = true
+		order[idx] = fldIdx
+	}
+
+	f.reorderFields(order)
+	f.record(reorderEdit(order))
+	f.fireSchemaChange()
+	return nil
+}
+
+// reorderFields changes order of fields: order contains old indexes
+// of fields in new order. Rows data is rewritten in new layout
+func (f *file) reorderFields(order []int) {
+	fields := make([]*field, len(order))
+	offset := 1 // fields starts after deletion flag
+	for idx, old := range order {
+		fld := *f.fields[old]
+		fld.idx = idx
+		fld.offset = offset
+		offset += fld.Len()
+		fields[idx] = &fld
+	}
+
+	rlen := f.RLen()
+	data := make([]byte, len(f.data))
+	for row := 0; row < f.Rows(); row++ {
+		src := f.data[row*rlen : (row+1)*rlen]
+		dst := data[row*rlen : (row+1)*rlen]
+		dst[0] = src[0] // deletion flag
+		for idx, old := range order {
+			copy(dst[fields[idx].offset:], src[f.fields[old].offset:f.fields[old].offset+fields[idx].Len()])
+		}
+	}
+	data[len(data)-1] = eof
+
+	f.fields = fields
+	f.fieldsIdx = make(map[string]int, len(fields))
+	for idx, fld := range fields {
+		f.fieldsIdx[fld.name] = idx
+	}
+	f.data = data
+	f.header.updateChanged()
+	f.layoutChanged()
+}
+
+// reorderEdit creates edit of fields reordering
+func reorderEdit(order []int) edit {
+	inverse := make([]int, len(order))
+	for idx, old := range order {
+		inverse[old] = idx
+	}
+	return edit{
+		undo: func(f *file) error {
+			f.reorderFields(inverse)
+			f.fireSchemaChange()
+			return nil
+		},
+		redo: func(f *file) error {
+			f.reorderFields(order)
+			f.fireSchemaChange()
+			return nil
+		},
+	}
+}
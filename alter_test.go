@@ -0,0 +1,173 @@
+package dbf3
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestAlterField(t *testing.T) {
+	f := newTestFile(t, 3, WithHistory(0))
+	if err := f.Set(2, "NAME", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	before := saveTestFile(t, f)
+
+	if _, err := f.AlterField("AMOUNT", Numeric, 10, 3); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 1, "AMOUNT", "1.500")
+
+	found, err := f.AlterField("NAME", Numeric, 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// names are not numbers
+	if len(found) != 3 {
+		t.Fatalf("expected 3 issues, got %v", found)
+	}
+	expectValue(t, f, 0, "NAME", "")
+
+	if _, err := f.AlterField("MISSING", Numeric, 5, 0); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+	if err := f.RenameField("AMOUNT", "TOTAL"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 1, "TOTAL", "1.500")
+	if err := f.RenameField("TOTAL", "NAME"); err == nil {
+		t.Fatal("expected error for existing field")
+	}
+
+	// two alters and rename are undone
+	for idx := 0; idx < 3; idx++ {
+		if err := f.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(before)) {
+		t.Fatal("file is not restored by undo")
+	}
+}
+
+func TestAlterFieldUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(f File) error
+		check  func(t *testing.T, f File)
+	}{
+		{"same length", func(f File) error {
+			_, err := f.AlterField("AMOUNT", Numeric, 10, 3)
+			return err
+		}, func(t *testing.T, f File) {
+			expectValue(t, f, 1, "AMOUNT", "1.500")
+		}},
+		{"replaced field", func(f File) error {
+			if err := f.AddField("NOTE", Character, 10, 0); err != nil {
+				return err
+			}
+			if err := f.Set(1, "NOTE", "note"); err != nil {
+				return err
+			}
+			return f.DelField("NAME")
+		}, func(t *testing.T, f File) {
+			expectValue(t, f, 1, "NOTE", "note")
+			expectValue(t, f, 1, "AMOUNT", "1.5")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "test.dbf")
+			if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := OpenUpdate(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.change(f); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			expectSynced(t, f, fileName)
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			g, err := OpenFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, g)
+		})
+	}
+}
+
+func TestReorderFields(t *testing.T) {
+	f := newTestFile(t, 3, WithHistory(0))
+	before := saveTestFile(t, f)
+
+	if err := f.ReorderFields([]string{"AMOUNT"}); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+	if err := f.ReorderFields([]string{"AMOUNT", "AMOUNT"}); err == nil {
+		t.Fatal("expected error for duplicate field")
+	}
+	if err := f.ReorderFields([]string{"AMOUNT", "NAME"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.Fields()[0].Name() != "AMOUNT" {
+		t.Fatal("fields are not reordered")
+	}
+	expectValue(t, f, 2, "NAME", "row2")
+	expectValue(t, f, 2, "AMOUNT", "2.5")
+
+	if err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(before)) {
+		t.Fatal("file is not restored by undo")
+	}
+}
+
+func TestReorderFieldsUpdate(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenUpdate(fileName, WithHistory(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ReorderFields([]string{"AMOUNT", "NAME"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expectSynced(t, f, fileName)
+
+	// reordering undone and redone before Close is written too
+	if err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Fields()[0].Name() != "AMOUNT" {
+		t.Fatal("fields are not reordered")
+	}
+	expectValue(t, g, 1, "NAME", "row1")
+	expectValue(t, g, 1, "AMOUNT", "1.5")
+}
@@ -0,0 +1,174 @@
+package dbf3
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	noArchive = iota
+	zipArchive
+	gzipArchive
+)
+
+// archiveKind detects archive format by signature
+func archiveKind(sig []byte) int {
+	switch {
+	case bytes.HasPrefix(sig, []byte("PK\x03\x04")):
+		return zipArchive
+	case bytes.HasPrefix(sig, []byte{0x1f, 0x8b}):
+		return gzipArchive
+	}
+	return noArchive
+}
+
+// OpenArchive opens DBF from zip or gzip archive. For zip archive
+// member is name of the table inside archive (can be empty if archive
+// contains single table). Language driver is taken from companion
+// .cpg file (inside zip archive or next to gzip archive),
+// if it's not specified by file header or options.
+// Other companion files (e.g. .dbt memo) are ignored,
+// since memo fields are not supported
+func OpenArchive(archive, member string, opts ...Option) (File, error) {
+	return openStored(archive, member, true, opts)
+}
+
+// openStored opens DBF or archive with DBF from storage
+func openStored(fileName, member string, archived bool, opts []Option) (File, error) {
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.storage == OSStorage {
+		if err := checkJournal(fileName); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := o.storage.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	br := bufio.NewReader(r)
+	sig, _ := br.Peek(4)
+	switch archiveKind(sig) {
+	case zipArchive:
+		return openZip(r, br, member, opts)
+	case gzipArchive:
+		return openGzip(br, fileName, o.storage, opts)
+	}
+
+	if archived {
+		return nil, errors.New("unknown archive format")
+	}
+	return Open(br, opts...)
+}
+
+// openZip opens DBF from zip archive. Archive is read through
+// io.ReaderAt if it's supported, otherwise it's read into memory
+func openZip(r io.Reader, br *bufio.Reader, member string, opts []Option) (File, error) {
+	var ra io.ReaderAt
+	var size int64
+	if rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		ra, size = rs, end
+	} else {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := findTable(zr.File, member)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenFS(zr, table.Name, opts...)
+}
+
+// findTable finds table with specified name (case insensitive) or,
+// if name is empty, the only table inside archive
+func findTable(files []*zip.File, name string) (*zip.File, error) {
+	if name != "" {
+		if zf := findMember(files, name); zf != nil {
+			return zf, nil
+		}
+		return nil, errors.New("table not found in archive")
+	}
+
+	var table *zip.File
+	for _, zf := range files {
+		if strings.EqualFold(path.Ext(zf.Name), ".dbf") {
+			if table != nil {
+				return nil, errors.New("archive contains several tables, " +
+					"table name must be specified by OpenArchive")
+			}
+			table = zf
+		}
+	}
+	if table == nil {
+		return nil, errors.New("table not found in archive")
+	}
+	return table, nil
+}
+
+func findMember(files []*zip.File, name string) *zip.File {
+	for _, zf := range files {
+		if strings.EqualFold(zf.Name, name) {
+			return zf
+		}
+	}
+	return nil
+}
+
+func openGzip(r io.Reader, fileName string, storage Storage, opts []Option) (File, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	table := strings.TrimSuffix(fileName, path.Ext(fileName)) // without .gz
+	if cpg, err := storage.Open(companionName(table, ".cpg")); err == nil {
+		lang, ok := readCPG(cpg)
+		cpg.Close()
+		if ok {
+			opts = append(opts, withCPGLang(lang))
+		}
+	}
+
+	return Open(gz, opts...)
+}
+
+// companionName returns name of companion file of table
+func companionName(table, ext string) string {
+	return strings.TrimSuffix(table, path.Ext(table)) + ext
+}
+
+func withCPGLang(lang LangID) func(*options) {
+	return func(o *options) {
+		o.cpgLang = lang
+	}
+}
@@ -0,0 +1,140 @@
+package dbf3
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeZip creates zip archive with specified files
+func writeZip(t *testing.T, fileName string, files map[string][]byte) {
+	t.Helper()
+	fd, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	zw := zip.NewWriter(fd)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenArchiveZip(t *testing.T) {
+	dir := t.TempDir()
+	table := saveTestFile(t, newTestFile(t, 2))
+
+	single := filepath.Join(dir, "single.zip")
+	writeZip(t, single, map[string][]byte{
+		"data/TABLE.DBF": table,
+		"data/table.cpg": []byte("ANSI 1251\n"),
+		"readme.txt":     []byte("readme"),
+	})
+	for _, tc := range []struct {
+		name string
+		open func() (File, error)
+	}{
+		{"by name", func() (File, error) { return OpenArchive(single, "data/table.dbf") }},
+		{"single table", func() (File, error) { return OpenArchive(single, "") }},
+		{"detected", func() (File, error) { return OpenFile(single) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := tc.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Lang() != Lang201 {
+				t.Fatalf("expected language from .cpg file, got %d", f.Lang())
+			}
+			expectValue(t, f, 1, "NAME", "row1")
+		})
+	}
+
+	// language of options takes precedence over .cpg file
+	f, err := OpenFile(single, WithLang(Lang38))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Lang() != Lang38 {
+		t.Fatalf("expected language of options, got %d", f.Lang())
+	}
+
+	several := filepath.Join(dir, "several.zip")
+	writeZip(t, several, map[string][]byte{
+		"first.dbf":  table,
+		"second.dbf": saveTestFile(t, newTestFile(t, 3)),
+	})
+	if _, err := OpenFile(several); err == nil || !strings.Contains(err.Error(), "several tables") {
+		t.Fatalf("expected error for several tables, got %v", err)
+	}
+	f, err = OpenArchive(several, "second.dbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 3 {
+		t.Fatalf("expected 3 rows, got %d", f.Rows())
+	}
+	if _, err := OpenArchive(several, "third.dbf"); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+}
+
+func TestOpenArchiveGzip(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "table.dbf.gz")
+
+	fd, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(fd)
+	if _, err := gw.Write(saveTestFile(t, newTestFile(t, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "table.cpg"), []byte("866"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, open := range []func() (File, error){
+		func() (File, error) { return OpenArchive(fileName, "") },
+		func() (File, error) { return OpenFile(fileName) },
+	} {
+		f, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Rows() != 2 || f.Lang() != Lang101 {
+			t.Fatalf("unexpected file: %d rows, language %d", f.Rows(), f.Lang())
+		}
+		expectValue(t, f, 0, "NAME", "row0")
+	}
+}
+
+func TestOpenArchiveNotArchive(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "table.dbf")
+	if err := newTestFile(t, 1).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenArchive(fileName, ""); err == nil {
+		t.Fatal("expected error for not archived table")
+	}
+}
@@ -0,0 +1,94 @@
+package dbf3
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// Report presents result of DBF integrity check
+type Report struct {
+	Rows   int     // count of rows, which can be read
+	Issues []Issue // found problems
+}
+
+// OK checks if no problems were found
+func (r *Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// signatures presents known signatures of xbase/dbase files
+var signatures = map[byte]bool{
+	0x02: true, // FoxBASE
+	0x03: true, // dBase III without memo
+	0x04: true, // dBase IV without memo
+	0x05: true, // dBase V without memo
+	0x30: true, // Visual FoxPro
+	0x31: true, // Visual FoxPro with autoincrement
+	0x32: true, // Visual FoxPro with varchar/varbinary
+	0x43: true, // dBase IV SQL table
+	0x63: true, // dBase IV SQL system file
+	0x7B: true, // dBase IV with memo
+	0x83: true, // dBase III with memo
+	0x8B: true, // dBase IV with memo
+	0xCB: true, // dBase IV SQL table with memo
+	0xE5: true, // Clipper SIX driver with SMT memo
+	0xF5: true, // FoxPro with memo
+	0xFB: true, // FoxBASE
+}
+
+// Check verifies integrity of DBF: header, fields, rows count,
+// EOF marker, deletion flags and values of fields.
+// Error is returned only if DBF cannot be read at all
+func Check(r io.Reader) (*Report, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var found issues
+	if len(buf) > 0 && !signatures[buf[0]] {
+		found.addHeader("unknown signature 0x" + strconv.FormatUint(uint64(buf[0]), 16))
+	}
+
+	ff, err := openRecover(bytes.NewReader(buf), &options{
+		convCtor: CharmapsTextConverter,
+		issues:   (*[]Issue)(&found),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fld := range ff.fields {
+		switch fld.Type() {
+		case Character, Date, Logical, Numeric:
+		default:
+			found.add(-1, fld.idx, "unsupported field type "+string(rune(fld.Type())))
+		}
+	}
+
+	for row := 0; row < ff.Rows(); row++ {
+		data, _ := ff.rowData(row)
+		if data[0] != blank && data[0] != deleted {
+			found.add(row, -1, "invalid deletion flag 0x"+strconv.FormatUint(uint64(data[0]), 16))
+		}
+
+		for _, fld := range ff.fields {
+			if err := checkValue(fld, data); err != nil {
+				found.add(row, fld.idx, err.Error())
+			}
+		}
+	}
+
+	return &Report{Rows: ff.Rows(), Issues: found}, nil
+}
+
+// checkValue checks raw value of the field
+func checkValue(fld *field, row []byte) error {
+	switch fld.Type() {
+	case Date, Logical, Numeric:
+		_, err := parseValue(fld.Type(), fld.value(row))
+		return err
+	}
+	return nil
+}
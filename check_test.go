@@ -0,0 +1,105 @@
+package dbf3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newCheckSeed(t *testing.T) (File, []byte) {
+	f := New()
+	for _, fld := range []struct {
+		name string
+		typ  FieldType
+		len  byte
+		dec  byte
+	}{
+		{"N", Numeric, 5, 1},
+		{"D", Date, 0, 0},
+		{"L", Logical, 0, 0},
+	} {
+		if err := f.AddField(fld.name, fld.typ, fld.len, fld.dec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := f.NewRow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, val := range map[string]string{"N": "1.5", "D": "20200101", "L": "T"} {
+		if err := f.Set(0, name, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Set does not check values yet
+	for name, val := range map[string]string{"N": "1e5", "D": "20201301", "L": "X"} {
+		if err := f.Set(1, name, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f, saveTestFile(t, f)
+}
+
+func TestCheck(t *testing.T) {
+	f, data := newCheckSeed(t)
+	data[f.HLen()+2*f.RLen()] = 'Z' // deletion flag of the last row
+
+	report, err := Check(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.Rows != 3 {
+		t.Fatalf("unexpected report: %d rows, %v", report.Rows, report.Issues)
+	}
+
+	expected := []struct {
+		row, field int
+	}{
+		{1, 0},  // invalid numeric
+		{1, 1},  // invalid date
+		{1, 2},  // invalid logical
+		{2, -1}, // invalid deletion flag
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), report.Issues)
+	}
+	for idx, exp := range expected {
+		issue := report.Issues[idx]
+		if issue.Row != exp.row || issue.Field != exp.field {
+			t.Errorf("issue %d: expected row %d, field %d, got %v", idx, exp.row, exp.field, issue)
+		}
+	}
+}
+
+func TestCheckValid(t *testing.T) {
+	report, err := Check(bytes.NewReader(saveTestFile(t, newTestFile(t, 3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Rows != 3 {
+		t.Fatalf("unexpected report: %d rows, %v", report.Rows, report.Issues)
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	data := saveTestFile(t, newTestFile(t, 3))
+	data[0] = 0x99
+	data = data[:len(data)-1] // without EOF marker
+
+	report, err := Check(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 3 || len(report.Issues) != 2 {
+		t.Fatalf("unexpected report: %d rows, %v", report.Rows, report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.Row != -1 || issue.Field != -1 {
+			t.Errorf("expected header issue, got %v", issue)
+		}
+	}
+
+	if _, err := Check(bytes.NewReader(data[:10])); err == nil {
+		t.Fatal("expected error for too short file")
+	}
+}
@@ -0,0 +1,299 @@
+package dbf3
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// syncFile presents file safe for concurrent use
+// (see WithConcurrentAccess option)
+type syncFile struct {
+	mu sync.RWMutex
+	f  *file
+}
+
+// wrap makes file safe for concurrent use if it's required by options
+func (o *options) wrap(f *file) File {
+	if !o.concurrent {
+		return f
+	}
+
+	// text converters are not safe for concurrent use
+	ctor := f.converterCtor
+	f.converterCtor = func(lang LangID) TextConverter {
+		return &syncConverter{c: ctor(lang)}
+	}
+	f.converter = f.converterCtor(f.Lang())
+	return &syncFile{f: f}
+}
+
+// syncConverter presents text converter safe for concurrent use
+type syncConverter struct {
+	mu sync.Mutex
+	c  TextConverter
+}
+
+func (sc *syncConverter) Encode(s string) (string, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.c.Encode(s)
+}
+
+func (sc *syncConverter) Decode(s string) (string, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.c.Decode(s)
+}
+
+// acquire returns implementation of specified file, locked for change
+// if it's safe for concurrent use. Returned function releases lock
+func acquire(f File) (*file, func(), bool) {
+	switch f := f.(type) {
+	case *file:
+		return f, func() {}, true
+	case *syncFile:
+		f.mu.Lock()
+		return f.f, f.mu.Unlock, true
+	}
+	return nil, nil, false
+}
+
+// rlock locks file for reading. Reading of disk-backed file
+// changes page cache, so it's locked exclusively
+func (sf *syncFile) rlock() func() {
+	if sf.f.disk != nil {
+		sf.mu.Lock()
+		return sf.mu.Unlock
+	}
+	sf.mu.RLock()
+	return sf.mu.RUnlock
+}
+
+func (sf *syncFile) lock() func() {
+	sf.mu.Lock()
+	return sf.mu.Unlock
+}
+
+func (sf *syncFile) Changed() time.Time {
+	defer sf.rlock()()
+	return sf.f.Changed()
+}
+
+func (sf *syncFile) Rows() int {
+	defer sf.rlock()()
+	return sf.f.Rows()
+}
+
+func (sf *syncFile) HLen() int {
+	defer sf.rlock()()
+	return sf.f.HLen()
+}
+
+func (sf *syncFile) RLen() int {
+	defer sf.rlock()()
+	return sf.f.RLen()
+}
+
+func (sf *syncFile) Lang() LangID {
+	defer sf.rlock()()
+	return sf.f.Lang()
+}
+
+func (sf *syncFile) SetLang(lang LangID) {
+	defer sf.lock()()
+	sf.f.SetLang(lang)
+}
+
+func (sf *syncFile) Fields() []Field {
+	defer sf.rlock()()
+	return sf.f.Fields()
+}
+
+func (sf *syncFile) HasField(field string) bool {
+	defer sf.rlock()()
+	return sf.f.HasField(field)
+}
+
+func (sf *syncFile) Row(idx int) (Row, error) {
+	defer sf.rlock()()
+	if _, err := sf.f.Row(idx); err != nil {
+		return nil, err
+	}
+	return &row{sf, idx}, nil
+}
+
+func (sf *syncFile) NewRow() (int, error) {
+	defer sf.lock()()
+	return sf.f.NewRow()
+}
+
+func (sf *syncFile) DelRow(idx int) error {
+	defer sf.lock()()
+	return sf.f.DelRow(idx)
+}
+
+func (sf *syncFile) Deleted(idx int) (bool, error) {
+	defer sf.rlock()()
+	return sf.f.Deleted(idx)
+}
+
+func (sf *syncFile) Pack() error {
+	defer sf.lock()()
+	return sf.f.Pack()
+}
+
+func (sf *syncFile) AddField(name string, typ FieldType, length, dec byte) error {
+	defer sf.lock()()
+	return sf.f.AddField(name, typ, length, dec)
+}
+
+func (sf *syncFile) DelField(field string) error {
+	defer sf.lock()()
+	return sf.f.DelField(field)
+}
+
+func (sf *syncFile) InsertField(pos int, name string, typ FieldType, length, dec byte) error {
+	defer sf.lock()()
+	return sf.f.InsertField(pos, name, typ, length, dec)
+}
+
+func (sf *syncFile) ReorderFields(names []string) error {
+	defer sf.lock()()
+	return sf.f.ReorderFields(names)
+}
+
+func (sf *syncFile) Schema() Schema {
+	defer sf.rlock()()
+	return sf.f.Schema()
+}
+
+func (sf *syncFile) RenameField(oldName, newName string) error {
+	defer sf.lock()()
+	return sf.f.RenameField(oldName, newName)
+}
+
+func (sf *syncFile) AlterField(name string, typ FieldType, length, dec byte) ([]Issue, error) {
+	defer sf.lock()()
+	return sf.f.AlterField(name, typ, length, dec)
+}
+
+func (sf *syncFile) Get(row int, field string) (string, error) {
+	defer sf.rlock()()
+	return sf.f.Get(row, field)
+}
+
+func (sf *syncFile) rawChar(row int, field string) (string, bool, error) {
+	defer sf.rlock()()
+	return sf.f.rawChar(row, field)
+}
+
+func (sf *syncFile) Value(row int, field string) (interface{}, error) {
+	defer sf.rlock()()
+	return sf.f.Value(row, field)
+}
+
+func (sf *syncFile) Set(row int, field, value string) error {
+	defer sf.lock()()
+	return sf.f.Set(row, field, value)
+}
+
+func (sf *syncFile) Save(w io.Writer) error {
+	defer sf.rlock()()
+	return sf.f.Save(w)
+}
+
+func (sf *syncFile) SaveFile(fileName string) error {
+	defer sf.rlock()()
+	return sf.f.SaveFile(fileName)
+}
+
+func (sf *syncFile) Flush() error {
+	defer sf.lock()()
+	return sf.f.Flush()
+}
+
+func (sf *syncFile) Close() error {
+	defer sf.lock()()
+	return sf.f.Close()
+}
+
+func (sf *syncFile) Begin() Tx {
+	defer sf.rlock()()
+	return sf.f.begin(sf.lock)
+}
+
+func (sf *syncFile) OnSet(fn func(row int, field, old, new string)) {
+	defer sf.lock()()
+	sf.f.OnSet(fn)
+}
+
+func (sf *syncFile) OnNewRow(fn func(row int)) {
+	defer sf.lock()()
+	sf.f.OnNewRow(fn)
+}
+
+func (sf *syncFile) OnDelRow(fn func(row int)) {
+	defer sf.lock()()
+	sf.f.OnDelRow(fn)
+}
+
+func (sf *syncFile) OnSchemaChange(fn func(fields []Field)) {
+	defer sf.lock()()
+	sf.f.OnSchemaChange(fn)
+}
+
+func (sf *syncFile) DirtyRows() []int {
+	defer sf.rlock()()
+	return sf.f.DirtyRows()
+}
+
+func (sf *syncFile) ResetDirty() {
+	defer sf.lock()()
+	sf.f.ResetDirty()
+}
+
+func (sf *syncFile) Undo() error {
+	defer sf.lock()()
+	return sf.f.Undo()
+}
+
+func (sf *syncFile) Redo() error {
+	defer sf.lock()()
+	return sf.f.Redo()
+}
+
+func (sf *syncFile) Checkpoint(name string) error {
+	defer sf.lock()()
+	return sf.f.Checkpoint(name)
+}
+
+func (sf *syncFile) RestoreCheckpoint(name string) error {
+	defer sf.lock()()
+	return sf.f.RestoreCheckpoint(name)
+}
+
+func (sf *syncFile) Clone() (File, error) {
+	defer sf.rlock()()
+	return sf.f.Clone()
+}
+
+func (sf *syncFile) Snapshot() (File, error) {
+	defer sf.lock()()
+	return sf.f.Snapshot()
+}
+
+func (sf *syncFile) LockFile() error {
+	defer sf.lock()()
+	return sf.f.LockFile()
+}
+
+func (sf *syncFile) LockRecord(idx int) error {
+	defer sf.lock()()
+	return sf.f.LockRecord(idx)
+}
+
+func (sf *syncFile) Unlock() error {
+	defer sf.lock()()
+	return sf.f.Unlock()
+}
@@ -0,0 +1,218 @@
+package dbf3
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func newConcurrentSeed(t *testing.T, opts ...Option) File {
+	f := New(opts...)
+	if err := f.AddField("NAME", Character, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddField("AMOUNT", Numeric, 8, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		idx, err := f.NewRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(idx, "NAME", "row"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(idx, "AMOUNT", strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// runConcurrently runs readers and writers in parallel
+// and checks consistency of read values
+func runConcurrently(t *testing.T, f File, schemaChanges bool) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				idx := i % f.Rows()
+				if val, err := f.Get(idx, "NAME"); err == nil && val != "row" && val != "changed" {
+					errs <- io.ErrUnexpectedEOF
+					return
+				}
+				if row, err := f.Row(idx); err == nil {
+					row.Value("AMOUNT")
+					row.Deleted()
+				}
+				f.Fields()
+				if err := f.Save(io.Discard); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if err := f.Set(i%f.Rows(), "NAME", "changed"); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := f.NewRow(); err != nil {
+				errs <- err
+				return
+			}
+			if err := f.Set(f.Rows()-1, "NAME", "row"); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	if schemaChanges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := f.AddField("EXTRA", Character, 5, 0); err != nil {
+					errs <- err
+					return
+				}
+				if err := f.DelField("EXTRA"); err != nil {
+					errs <- err
+					return
+				}
+				if err := f.DelRow(0); err == nil {
+					if err := f.Pack(); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	f := newConcurrentSeed(t, WithConcurrentAccess())
+	runConcurrently(t, f, true)
+
+	var buf bytes.Buffer
+	if err := f.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Open(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Rows() != f.Rows() {
+		t.Fatalf("rows count %d differs from %d", saved.Rows(), f.Rows())
+	}
+}
+
+func TestConcurrentAccessDisk(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newConcurrentSeed(t).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(fileName, WithConcurrentAccess(), WithPageCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	runConcurrently(t, f, false)
+}
+
+func TestSnapshotWhileChanging(t *testing.T) {
+	for _, disk := range []bool{false, true} {
+		f := newConcurrentSeed(t)
+		if disk {
+			fileName := filepath.Join(t.TempDir(), "test.dbf")
+			if err := f.SaveFile(fileName); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if f, err = OpenFileAt(fileName); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var expected bytes.Buffer
+		if err := f.Save(&expected); err != nil {
+			t.Fatal(err)
+		}
+		snap, err := f.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan error)
+		go func() {
+			for i := 0; i < 20; i++ {
+				var buf bytes.Buffer
+				if err := snap.Save(&buf); err != nil {
+					done <- err
+					return
+				}
+				if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+					done <- io.ErrUnexpectedEOF
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		for i := 0; i < 200; i++ {
+			if err := f.Set(i%f.Rows(), "NAME", "changed"); err != nil {
+				t.Fatal(err)
+			}
+			if i%50 == 0 {
+				if err := f.DelRow(i % f.Rows()); err != nil {
+					t.Fatal(err)
+				}
+				if err := f.Pack(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := f.NewRow(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := snap.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+			t.Fatal("snapshot changed after file was closed")
+		}
+		if err := snap.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package dbf3
+
+import (
+	"io"
+	"math"
+	"sync"
+)
+
+// views presents snapshots sharing rows data with file
+type views struct {
+	mu   sync.RWMutex
+	list []*view
+}
+
+// view presents rows data of snapshot: pages of data, which were
+// changed in file after snapshot was taken, are preserved (copied)
+// before change, other pages are read from file
+type view struct {
+	mu    *sync.RWMutex // shared with file
+	size  int64         // size of rows data
+	read  func(p []byte, off int64) error
+	base  *byte // first byte of in-memory rows data (nil for disk-backed file)
+	pages map[int64][]byte
+}
+
+func (f *file) Snapshot() (File, error) {
+	if f.views == nil {
+		f.views = &views{}
+	}
+
+	v := &view{
+		mu:    &f.views.mu,
+		size:  int64(f.Rows()) * int64(f.RLen()),
+		pages: make(map[int64][]byte),
+	}
+	parent := f.view
+	switch {
+	case parent != nil:
+		// snapshot of snapshot reads the source file as its parent
+		// does, so it doesn't depend on parent after it's taken
+		v.read, v.base = parent.read, parent.base
+	case f.disk != nil:
+		rw, hlen := f.disk.rw, int64(f.header.hlen)
+		v.read = func(p []byte, off int64) error {
+			_, err := rw.ReadAt(p, hlen+off)
+			return err
+		}
+	default:
+		data := f.data
+		v.base = &data[0]
+		v.read = func(p []byte, off int64) error {
+			copy(p, data[off:])
+			return nil
+		}
+	}
+
+	f.views.mu.Lock()
+	if parent != nil {
+		// pages preserved by parent are not changed anymore
+		for page, data := range parent.pages {
+			v.pages[page] = data
+		}
+	}
+	f.views.list = append(f.views.list, v)
+	f.views.mu.Unlock()
+
+	snap := f.copyLayout()
+	snap.view = v
+	// snapshots of snapshot are registered in the source file
+	snap.views = f.views
+	snap.readOnly = true
+	snap.closer = &viewCloser{f.views, v}
+	return snap, nil
+}
+
+func (f *file) Clone() (File, error) {
+	clone := f.copyLayout()
+	clone.history = f.history.clone()
+
+	if f.disk == nil && f.view == nil {
+		clone.data = append([]byte(nil), f.data...)
+		return clone, nil
+	}
+
+	clone.data = make([]byte, f.Rows()*f.RLen()+1)
+	for row := 0; row < f.Rows(); row++ {
+		data, err := f.rowData(row)
+		if err != nil {
+			return nil, err
+		}
+		copy(clone.data[row*f.RLen():], data)
+	}
+	clone.data[len(clone.data)-1] = eof
+	return clone, nil
+}
+
+// copyLayout creates in-memory file with copy of header and fields
+// (without rows data)
+func (f *file) copyLayout() *file {
+	c := &file{
+		header:        f.header,
+		fields:        make([]*field, len(f.fields)),
+		fieldsIdx:     make(map[string]int, len(f.fieldsIdx)),
+		converterCtor: f.converterCtor,
+		converter:     f.converterCtor(f.Lang()),
+		storage:       f.storage,
+		backup:        f.backup,
+	}
+	c.header.transaction = 0
+	for idx := range f.fields {
+		fld := *f.fields[idx]
+		c.fields[idx] = &fld
+		c.fieldsIdx[fld.name] = idx
+	}
+	return c
+}
+
+// clone copies history (edits are shared, since they are not changed)
+func (h *history) clone() *history {
+	if h == nil {
+		return nil
+	}
+
+	c := newHistory(h.limit)
+	c.size = h.size
+	c.pos = h.pos
+	c.edits = append([]edit(nil), h.edits...)
+	for name, pos := range h.marks {
+		c.marks[name] = pos
+	}
+	return c
+}
+
+// preserve copies pages of rows data in specified range
+// into snapshots before the range is changed
+func (f *file) preserve(from, to int64) error {
+	if f.views == nil {
+		return nil
+	}
+
+	f.views.mu.Lock()
+	defer f.views.mu.Unlock()
+
+	list := f.views.list[:0]
+	for _, v := range f.views.list {
+		if v.base != nil && (len(f.data) == 0 || v.base != &f.data[0]) {
+			// data was reallocated, so view is not affected by changes anymore
+			continue
+		}
+		list = append(list, v)
+	}
+	for idx := len(list); idx < len(f.views.list); idx++ {
+		f.views.list[idx] = nil
+	}
+	f.views.list = list
+
+	for _, v := range list {
+		if err := v.preserve(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachViews copies all data shared with snapshots
+// (before file is closed)
+func (f *file) detachViews() error {
+	if err := f.preserve(0, math.MaxInt64); err != nil {
+		return err
+	}
+	if f.views != nil {
+		f.views.mu.Lock()
+		f.views.list = nil
+		f.views.mu.Unlock()
+	}
+	return nil
+}
+
+// preserve copies pages in specified range (must be called with lock)
+func (v *view) preserve(from, to int64) error {
+	if to > v.size {
+		to = v.size
+	}
+	for page := from / pageSize; page*pageSize < to; page++ {
+		if _, ok := v.pages[page]; ok {
+			continue
+		}
+
+		n := v.size - page*pageSize
+		if n > pageSize {
+			n = pageSize
+		}
+		buf := make([]byte, n)
+		if err := v.read(buf, page*pageSize); err != nil {
+			return err
+		}
+		v.pages[page] = buf
+	}
+	return nil
+}
+
+// readAt reads rows data of snapshot
+func (v *view) readAt(p []byte, off int64) error {
+	if off < 0 || off+int64(len(p)) > v.size {
+		return io.ErrUnexpectedEOF
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for len(p) > 0 {
+		page := off / pageSize
+		pageOff := off - page*pageSize
+		n := int64(len(p))
+		if n > pageSize-pageOff {
+			n = pageSize - pageOff
+		}
+		if data, ok := v.pages[page]; ok {
+			copy(p[:n], data[pageOff:])
+		} else if err := v.read(p[:n], off); err != nil {
+			return err
+		}
+		p, off = p[n:], off+n
+	}
+	return nil
+}
+
+// writeTo writes rows data and EOF marker of snapshot
+func (v *view) writeTo(w io.Writer) error {
+	buf := make([]byte, pageSize)
+	for off := int64(0); off < v.size; off += pageSize {
+		n := v.size - off
+		if n > pageSize {
+			n = pageSize
+		}
+		if err := v.readAt(buf[:n], off); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{eof})
+	return err
+}
+
+// viewCloser releases snapshot data
+type viewCloser struct {
+	views *views
+	view  *view
+}
+
+func (vc *viewCloser) Close() error {
+	vc.views.mu.Lock()
+	defer vc.views.mu.Unlock()
+
+	for idx, v := range vc.views.list {
+		if v == vc.view {
+			vc.views.list = append(vc.views.list[:idx], vc.views.list[idx+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
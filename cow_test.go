@@ -0,0 +1,116 @@
+package dbf3
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	f := newTestFile(t, 3)
+	before := saveTestFile(t, f)
+
+	s, err := f.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewRow(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectValue(t, s, 0, "NAME", "row0")
+	if s.Rows() != 3 {
+		t.Fatalf("expected 3 rows in snapshot, got %d", s.Rows())
+	}
+	if !bytes.Equal(saveTestFile(t, s), before) {
+		t.Fatal("snapshot is changed")
+	}
+	if err := s.Set(0, "NAME", "other"); err == nil {
+		t.Fatal("expected error for read only snapshot")
+	}
+}
+
+func TestNestedSnapshot(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) File
+	}{
+		{"memory", func(t *testing.T) File { return newTestFile(t, 4000) }},
+		{"disk", func(t *testing.T) File {
+			fileName := filepath.Join(t.TempDir(), "test.dbf")
+			if err := newTestFile(t, 4000).SaveFile(fileName); err != nil {
+				t.Fatal(err)
+			}
+			f, err := OpenFileAt(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.open(t)
+
+			parent, err := f.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// page of parent is preserved before child is taken,
+			// other pages (rows data is larger than a page) are not
+			if err := f.Set(1, "NAME", "first"); err != nil {
+				t.Fatal(err)
+			}
+			child, err := parent.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer child.Close()
+
+			// child doesn't depend on closed parent
+			if err := parent.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Set(0, "NAME", "changed"); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Set(1, "NAME", "second"); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Set(3500, "NAME", "changed"); err != nil {
+				t.Fatal(err)
+			}
+
+			expectValue(t, child, 0, "NAME", "row0")
+			expectValue(t, child, 1, "NAME", "row1")
+			expectValue(t, child, 3500, "NAME", "row3500")
+			expectValue(t, f, 0, "NAME", "changed")
+		})
+	}
+}
+
+func TestSnapshotAfterClose(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFileAt(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// data of closed file is copied into snapshot
+	expectValue(t, s, 2, "NAME", "row2")
+}
@@ -5,7 +5,6 @@ import (
 	"bufio"
 	"errors"
 	"io"
-	"os"
 	"time"
 )
 
@@ -41,25 +40,199 @@ type File interface {
 	AddField(name string, typ FieldType, length, dec byte) error
 	// DelField deletes field from file (with all values of that field in all rows)
 	DelField(field string) error
+	// InsertField adds new field in file at specified position
+	InsertField(pos int, name string, typ FieldType, length, dec byte) error
+	// ReorderFields changes order of fields (names must contain all fields)
+	ReorderFields(names []string) error
+	// Schema returns current layout of file
+	Schema() Schema
+	// RenameField changes name of field
+	RenameField(oldName, newName string) error
+	// AlterField changes type, length and decimal count of field,
+	// converting its values in all rows. Values, which can't be
+	// converted completely (truncated, rounded or blanked),
+	// are reported as issues
+	AlterField(name string, typ FieldType, length, dec byte) ([]Issue, error)
 	// Get returns field value from row with specified index
 	Get(row int, field string) (value string, err error)
+	// Value returns typed field value from row with specified index
+	// (string, float64, time.Time or bool depending on field type)
+	Value(row int, field string) (value interface{}, err error)
 	// Set sets field value in row with specified index
 	Set(row int, field, value string) error
 	// Save writes dbf into specified io.Writer
 	Save(w io.Writer) error
-	// SaveFile saves dbf into file with specified name
+	// SaveFile saves dbf into file with specified name.
+	// Data is written into temporary file, which replaces
+	// the original one only after it's written completely
+	// (see also WithBackup option). File opened for update
+	// and disk-backed file cannot be saved over itself (see Flush)
 	SaveFile(fileName string) error
+	// Flush writes pending changes of file opened for update
+	// or disk-backed file into underlying file
+	Flush() error
+	// Close flushes pending changes and closes underlying file
+	// (if it was opened by name) or memory mapping
+	Close() error
+	// LockFile locks whole disk-backed file against changes
+	// by other processes (until Unlock or Close).
+	// Locks belong to the process (see WithLockScheme)
+	LockFile() error
+	// LockRecord locks row of disk-backed file with specified index
+	// against changes by other processes (until Unlock or Close).
+	// Locks belong to the process (see WithLockScheme)
+	LockRecord(idx int) error
+	// Unlock releases all locks of file
+	Unlock() error
+	// Begin starts transaction. File must not be changed
+	// outside of transaction until it's finished
+	Begin() Tx
+	// OnSet registers callback called after field value is set.
+	// Callbacks are called synchronously and must not change the file
+	// (or use it at all, if file is opened with WithConcurrentAccess)
+	OnSet(fn func(row int, field, old, new string))
+	// OnNewRow registers callback called after row is created
+	OnNewRow(fn func(row int))
+	// OnDelRow registers callback called after row is marked as deleted
+	OnDelRow(fn func(row int))
+	// OnSchemaChange registers callback called after field is added or deleted
+	OnSchemaChange(fn func(fields []Field))
+	// DirtyRows returns sorted indexes of rows changed since
+	// the file was opened or since the last ResetDirty
+	DirtyRows() []int
+	// ResetDirty forgets changed rows
+	ResetDirty()
+	// Undo reverts the last edit (see WithHistory)
+	Undo() error
+	// Redo repeats the last undone edit
+	Redo() error
+	// Checkpoint names current state of history
+	Checkpoint(name string) error
+	// RestoreCheckpoint undoes or redoes edits up to named checkpoint
+	RestoreCheckpoint(name string) error
+	// Clone returns independent in-memory copy of file
+	Clone() (File, error)
+	// Snapshot returns read only view of current state of file,
+	// which is not affected by further changes. Data is shared
+	// with file until it's changed (pages of data are copied before
+	// change). Snapshot can be read while file is changed
+	// and should be closed when it's not needed anymore
+	Snapshot() (File, error)
+}
+
+// Tx presents transaction: changes are buffered
+// and applied by Commit all at once (or not applied at all)
+type Tx interface {
+	// NewRow creates new row and returns its index
+	NewRow() (idx int, err error)
+	// DelRow marks row with specified index as deleted
+	DelRow(idx int) error
+	// AddField adds new field in file (in the end of row)
+	AddField(name string, typ FieldType, length, dec byte) error
+	// DelField deletes field from file
+	DelField(field string) error
+	// Set sets field value in row with specified index
+	Set(row int, field, value string) error
+	// Commit applies buffered changes. If any change fails,
+	// file is restored to the state before Commit.
+	// Change callbacks are called after all changes are applied
+	// (and are not called if changes are rolled back).
+	// Incomplete transaction flag of disk-backed file
+	// is set while changes are written
+	Commit() error
+	// Rollback discards buffered changes
+	Rollback() error
+}
+
+// Reader presents DBF stream reader interface,
+// which reads rows one by one without loading whole file into memory
+type Reader interface {
+	// Changed returns date of last file change
+	Changed() time.Time
+	// Rows returns rows count
+	Rows() int
+	// HLen returns length of file header
+	HLen() int
+	// RLen returns length of file row
+	RLen() int
+	// Lang returns language driver identifier
+	Lang() LangID
+	// Fields returns fields list
+	Fields() []Field
+	// HasField checks if file contains field with specified name
+	HasField(field string) bool
+	// Read reads next row (read only).
+	// Returns io.EOF when there are no more rows
+	Read() (row Row, err error)
+}
+
+// Writer presents DBF stream writer interface,
+// which writes rows one by one without buffering whole file in memory
+type Writer interface {
+	// Fields returns fields list
+	Fields() []Field
+	// Rows returns count of written rows
+	// (including existing rows of appended file)
+	Rows() int
+	// WriteRow writes row with specified values (in fields order)
+	WriteRow(values []string) error
+	// Close completes writing: writes EOF marker
+	// and patches rows count into the header
+	Close() error
 }
 
 type options struct {
-	lang     LangID
-	convCtor TextConverterCtor
+	lang       LangID
+	convCtor   TextConverterCtor
+	rows       int
+	cachePages int
+	recovery   bool
+	issues     *[]Issue
+	cpgLang    LangID // language from companion .cpg file
+	storage    Storage
+	backup     bool
+
+	lockScheme  LockScheme
+	lockTimeout time.Duration
+
+	concurrent bool
+	journal    bool
+	history    bool
+	historyMax int
+}
+
+// newFile creates file with specified header, fields and rows data
+func (o *options) newFile(hdr header, fields []*field, fieldsIdx map[string]int, data []byte) *file {
+	return &file{
+		header:        hdr,
+		fields:        fields,
+		data:          data,
+		fieldsIdx:     fieldsIdx,
+		converterCtor: o.convCtor,
+		converter:     o.convCtor(LangID(hdr.lang)),
+		storage:       o.storage,
+		backup:        o.backup,
+		history:       o.newHistory(),
+	}
+}
+
+// applyLang sets language driver of opened file
+// if it's specified by options or companion file
+func (o *options) applyLang(hdr *header) {
+	if o.lang != LangDefault {
+		hdr.lang = byte(o.lang)
+	} else if hdr.lang == byte(LangDefault) {
+		hdr.lang = byte(o.cpgLang)
+	}
 }
 
 func newDefaultOptions() *options {
 	return &options{
-		lang:     LangDefault,
-		convCtor: CharmapsTextConverter,
+		lang:       LangDefault,
+		convCtor:   CharmapsTextConverter,
+		rows:       -1,
+		cachePages: defaultCachePages,
+		storage:    OSStorage,
 	}
 }
 
@@ -82,44 +255,182 @@ func WithTextConverter(ctor TextConverterCtor) func(*options) {
 	}
 }
 
+// WithRows presents rows count option,
+// used by Writer when rows count is known in advance
+func WithRows(rows int) func(*options) {
+	return func(o *options) {
+		o.rows = rows
+	}
+}
+
+// WithPageCache presents option of pages count
+// in the cache of disk-backed file
+func WithPageCache(pages int) func(*options) {
+	return func(o *options) {
+		o.cachePages = pages
+	}
+}
+
+// WithRecovery presents tolerant open option: instead of failing
+// on damaged or non-conforming file, Open reads whatever rows
+// are readable and appends worked around problems to issues
+// (if it's not nil)
+func WithRecovery(issues *[]Issue) func(*options) {
+	return func(o *options) {
+		o.recovery = true
+		o.issues = issues
+	}
+}
+
+// WithStorage presents storage option, used by OpenFile,
+// OpenArchive and SaveFile instead of operating system files.
+// OpenFileAt, OpenUpdate, OpenMmap and OpenAppend always use
+// operating system files
+func WithStorage(s Storage) func(*options) {
+	return func(o *options) {
+		if s != nil {
+			o.storage = s
+		}
+	}
+}
+
+// WithBackup presents option to keep previous version
+// of the file as .bak file on SaveFile
+func WithBackup() func(*options) {
+	return func(o *options) {
+		o.backup = true
+	}
+}
+
+// WithLockScheme presents option of locking scheme (LockFoxPro by default),
+// which must match the scheme of other programs sharing the file.
+// Locks are placed by fcntl, so they belong to the process, not to
+// the opened file: locks of the same process don't conflict, and
+// they are all released when any descriptor of the table is closed
+// by the process, e.g. by Close of another file opened by name
+// (including OpenFile) or by rollback of interrupted update on open
+// (see WithJournal). So the table should be opened once per process
+// while it's locked
+func WithLockScheme(scheme LockScheme) func(*options) {
+	return func(o *options) {
+		o.lockScheme = scheme
+	}
+}
+
+// WithLockTimeout presents option of time to wait for locks, taken
+// by other processes (locks are not waited for by default)
+func WithLockTimeout(timeout time.Duration) func(*options) {
+	return func(o *options) {
+		o.lockTimeout = timeout
+	}
+}
+
+// WithConcurrentAccess presents option to make file safe for concurrent
+// use by multiple goroutines: reads are performed concurrently,
+// changes are serialized and wait for running reads
+func WithConcurrentAccess() func(*options) {
+	return func(o *options) {
+		o.concurrent = true
+	}
+}
+
+// WithJournal presents option to write journal (file with .wal suffix)
+// of changes of file opened by OpenFileAt or OpenUpdate. Changes
+// become durable on Flush (or Commit of transaction), and if they
+// are interrupted, file is rolled back to the state of the last Flush
+// on next open by OpenFileAt, OpenUpdate or OpenAppend. Journal is
+// locked while it's used, so journal of update in progress is not
+// rolled back by other processes. Read only opens (OpenFile, OpenMmap)
+// don't roll back interrupted update, but return error.
+// On platforms without file locking (e.g. Windows) journal cannot
+// be locked, so opens return error if journal exists, and interrupted
+// update must be rolled back by RollbackJournal
+func WithJournal() func(*options) {
+	return func(o *options) {
+		o.journal = true
+	}
+}
+
+// WithHistory presents option to record history of edits
+// for Undo and Redo. Edits are dropped from the oldest one,
+// when data kept by history (with approximate overhead of each edit)
+// exceeds maxBytes (if it's positive)
+func WithHistory(maxBytes int) func(*options) {
+	return func(o *options) {
+		o.history = true
+		o.historyMax = maxBytes
+	}
+}
+
+func (o *options) newHistory() *history {
+	if !o.history {
+		return nil
+	}
+	return newHistory(o.historyMax)
+}
+
 // New creates new empty DBF file
 func New(opts ...Option) File {
-	now := time.Now()
-
 	o := newDefaultOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
-	return &file{
-		header: header{
-			signature: 0x03, // dbase 3 without DBT
-			hlen:      33,   // header + terminator
-			rlen:      1,    // no fields + deletion flag
-			lang:      byte(o.lang),
-			changed: [3]byte{
-				byte(now.Year() - 1900),
-				byte(now.Month()),
-				byte(now.Day()),
-			},
-		},
-		data:          []byte{eof}, // EOF only
-		fieldsIdx:     make(map[string]int),
-		converterCtor: o.convCtor,
-		converter:     o.convCtor(o.lang),
-	}
+	return o.wrap(o.newFile(newHeader(o.lang), nil, make(map[string]int), []byte{eof})) // EOF only
 }
 
 // Open opens DBF from reader
 func Open(rd io.Reader, opts ...Option) (File, error) {
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	f, err := open(rd, o)
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(f), nil
+}
+
+func open(rd io.Reader, o *options) (*file, error) {
+	if o.recovery {
+		return openRecover(rd, o)
+	}
+
 	r := bufio.NewReader(rd)
 
+	hdr, fields, fieldsIdx, err := readHead(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// rows count is not trusted, so buffer grows while data is read
+	size := int64(hdr.rlen)*int64(hdr.rows) + 1
+	buf, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) != size {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	o.applyLang(&hdr)
+
+	return o.newFile(hdr, fields, fieldsIdx, buf), nil
+}
+
+// readHead reads header and fields descriptors
+func readHead(r io.Reader) (header, []*field, map[string]int, error) {
 	buf := make([]byte, 32)
 	if _, err := io.ReadFull(r, buf); err != nil {
-		return nil, err
+		return header{}, nil, nil, err
 	}
 
-	hdr := readHeader(buf)
+	hdr, err := readHeader(buf)
+	if err != nil {
+		return header{}, nil, nil, err
+	}
 
 	fields := make([]*field, (int(hdr.hlen)-33)/32)
 	fieldsIdx := make(map[string]int)
@@ -128,55 +439,39 @@ func Open(rd io.Reader, opts ...Option) (File, error) {
 
 	buf = make([]byte, int(hdr.hlen)-32)
 	if _, err := io.ReadFull(r, buf); err != nil {
-		return nil, err
+		return header{}, nil, nil, err
 	}
 
 	for idx := range fields {
-		fd.readFrom(buf[idx*32:])
+		if err := fd.readFrom(buf[idx*32:]); err != nil {
+			return header{}, nil, nil, err
+		}
 
-		fields[idx] = newField(fd, idx, offset)
+		fields[idx], err = newField(fd, idx, offset)
+		if err != nil {
+			return header{}, nil, nil, err
+		}
 		fieldsIdx[fields[idx].Name()] = idx
 		offset += fields[idx].Len()
 	}
 
 	term := buf[len(fields)*32]
 	if term != hterm {
-		return nil, errors.New("not expected header terminator")
+		return header{}, nil, nil, errors.New("not expected header terminator")
 	}
-
-	buf = make([]byte, int(hdr.rlen)*int(hdr.rows)+1)
-	if _, err := io.ReadFull(r, buf); err != nil {
-		return nil, err
+	if offset > int(hdr.rlen) {
+		return header{}, nil, nil, errors.New("fields exceed row length")
 	}
 
-	o := newDefaultOptions()
-	for _, opt := range opts {
-		opt(o)
-	}
-
-	if o.lang != LangDefault {
-		hdr.lang = byte(o.lang)
-	}
-
-	return &file{
-		header:        hdr,
-		fields:        fields,
-		data:          buf,
-		fieldsIdx:     fieldsIdx,
-		converterCtor: o.convCtor,
-		converter:     o.convCtor(LangID(hdr.lang)),
-	}, nil
+	return hdr, fields, fieldsIdx, nil
 }
 
-// OpenFile opens DBF from file
+// OpenFile opens DBF from file. Zip and gzip archives
+// are detected automatically (see OpenArchive). Zip archive
+// must contain single table, otherwise table must be
+// specified by OpenArchive
 func OpenFile(fileName string, opts ...Option) (File, error) {
-	file, err := os.Open(fileName)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	return Open(file, opts...)
+	return openStored(fileName, "", false, opts)
 }
 
 // Field presents DBF field descriptor
@@ -204,12 +499,17 @@ const (
 
 // Row presents DBF row interface
 type Row interface {
+	// Index returns index of the row
+	Index() int
 	// Deleted checks if row marked as deleted
 	Deleted() bool
 	// Del marks row as deleted
 	Del() error
 	// Get returns field value
 	Get(field string) (value string, err error)
+	// Value returns typed field value
+	// (string, float64, time.Time or bool depending on field type)
+	Value(field string) (value interface{}, err error)
 	// Set sets field value
 	Set(field, value string) error
 }
@@ -0,0 +1,309 @@
+package dbf3
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"os"
+)
+
+// ReaderWriterAt presents random access storage of disk-backed file
+type ReaderWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+const (
+	defaultCachePages = 16
+	pageSize          = 64 * 1024 // approximate size of cache page
+)
+
+// OpenAt opens disk-backed DBF. Only header and fields are kept
+// in memory, rows are read and written on demand through
+// the page cache (see WithPageCache option).
+// If rw is *os.File, rows are locked while they are changed
+// (see LockRecord and LockFile)
+func OpenAt(rw ReaderWriterAt, opts ...Option) (File, error) {
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	f, err := openAt(rw, o)
+	if err != nil {
+		return nil, err
+	}
+	return o.wrap(f), nil
+}
+
+func openAt(rw ReaderWriterAt, o *options) (*file, error) {
+	hdr, fields, fieldsIdx, err := readHead(io.NewSectionReader(rw, 0, 1<<31))
+	if err != nil {
+		return nil, err
+	}
+
+	o.applyLang(&hdr)
+
+	f := o.newFile(hdr, fields, fieldsIdx, nil)
+	f.disk = newDiskStorage(rw, &f.header, o.cachePages)
+	if fd, ok := rw.(*os.File); ok {
+		f.lock = newLocker(fd, o)
+	}
+	return f, nil
+}
+
+// OpenFileAt opens disk-backed DBF from file (for reading and writing).
+// File must be closed by Close method
+func OpenFileAt(fileName string, opts ...Option) (File, error) {
+	if err := recoverJournal(fileName, false); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var rw ReaderWriterAt = fd
+	var j *journal
+	if o.journal {
+		j = newJournal(fd, fileName)
+		rw = j
+	}
+
+	f, err := openAt(rw, o)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	f.closer = fd
+	f.journal = j
+	if f.lock == nil {
+		f.lock = newLocker(fd, o)
+	}
+	return o.wrap(f), nil
+}
+
+// diskStorage presents rows storage of disk-backed file
+// with LRU cache of pages (groups of adjacent rows)
+type diskStorage struct {
+	rw       ReaderWriterAt
+	header   *header
+	perPage  int // rows per page
+	maxPages int
+
+	pages map[int]*list.Element
+	lru   *list.List
+}
+
+type page struct {
+	idx  int
+	data []byte
+}
+
+func newDiskStorage(rw ReaderWriterAt, hdr *header, maxPages int) *diskStorage {
+	perPage := pageSize / int(hdr.rlen)
+	if perPage < 1 {
+		perPage = 1
+	}
+	if maxPages < 1 {
+		maxPages = 1
+	}
+
+	return &diskStorage{
+		rw:       rw,
+		header:   hdr,
+		perPage:  perPage,
+		maxPages: maxPages,
+		pages:    make(map[int]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (ds *diskStorage) rlen() int {
+	return int(ds.header.rlen)
+}
+
+func (ds *diskStorage) offset(idx int) int64 {
+	return int64(ds.header.hlen) + int64(idx)*int64(ds.rlen())
+}
+
+func (ds *diskStorage) row(idx int) ([]byte, error) {
+	p, err := ds.page(idx / ds.perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (idx % ds.perPage) * ds.rlen()
+	return p.data[offset : offset+ds.rlen()], nil
+}
+
+func (ds *diskStorage) page(idx int) (*page, error) {
+	if el, ok := ds.pages[idx]; ok {
+		ds.lru.MoveToFront(el)
+		return el.Value.(*page), nil
+	}
+
+	rows := int(ds.header.rows) - idx*ds.perPage
+	if rows > ds.perPage {
+		rows = ds.perPage
+	}
+	p := &page{idx: idx, data: make([]byte, rows*ds.rlen())}
+	if _, err := ds.rw.ReadAt(p.data, ds.offset(idx*ds.perPage)); err != nil {
+		return nil, err
+	}
+
+	if ds.lru.Len() >= ds.maxPages {
+		last := ds.lru.Back()
+		ds.lru.Remove(last)
+		delete(ds.pages, last.Value.(*page).idx)
+	}
+	ds.pages[idx] = ds.lru.PushFront(p)
+	return p, nil
+}
+
+func (ds *diskStorage) writeRow(idx int, data []byte) error {
+	if _, err := ds.rw.WriteAt(data, ds.offset(idx)); err != nil {
+		// cached page may not match the disk anymore
+		ds.dropPage(idx / ds.perPage)
+		return err
+	}
+
+	if el, ok := ds.pages[idx/ds.perPage]; ok {
+		copy(el.Value.(*page).data[(idx%ds.perPage)*ds.rlen():], data)
+	}
+	return nil
+}
+
+func (ds *diskStorage) appendRow(data []byte) error {
+	idx := int(ds.header.rows)
+	buf := make([]byte, len(data)+1)
+	copy(buf, data)
+	buf[len(data)] = eof
+	if _, err := ds.rw.WriteAt(buf, ds.offset(idx)); err != nil {
+		return err
+	}
+
+	ds.header.rows++
+	if err := ds.writeHeader(); err != nil {
+		ds.header.rows--
+		return err
+	}
+
+	// last page may be cached partially
+	ds.dropPage(idx / ds.perPage)
+	return nil
+}
+
+func (ds *diskStorage) truncate(rows int) error {
+	if _, err := ds.rw.WriteAt([]byte{eof}, ds.offset(rows)); err != nil {
+		return err
+	}
+
+	ds.header.rows = uint32(rows)
+	if err := ds.writeHeader(); err != nil {
+		return err
+	}
+
+	if err := truncate(ds.rw, ds.offset(rows)+1); err != nil {
+		return err
+	}
+
+	for idx := range ds.pages {
+		if idx*ds.perPage+ds.perPage > rows {
+			ds.dropPage(idx)
+		}
+	}
+	return nil
+}
+
+// refreshRow re-reads row in cached page
+// (it could be changed by other processes)
+func (ds *diskStorage) refreshRow(idx int) error {
+	el, ok := ds.pages[idx/ds.perPage]
+	if !ok {
+		return nil
+	}
+
+	p := el.Value.(*page)
+	offset := (idx % ds.perPage) * ds.rlen()
+	if offset >= len(p.data) {
+		return nil
+	}
+	if _, err := ds.rw.ReadAt(p.data[offset:offset+ds.rlen()], ds.offset(idx)); err != nil {
+		ds.dropPage(p.idx)
+		return err
+	}
+	return nil
+}
+
+// dropAll drops all cached pages
+func (ds *diskStorage) dropAll() {
+	for idx := range ds.pages {
+		ds.dropPage(idx)
+	}
+}
+
+// reload reads rows count and date of last change
+// (they could be changed by other processes).
+// Cached pages after the end of rows are dropped,
+// if rows count was changed
+func (ds *diskStorage) reload() error {
+	buf := make([]byte, 32)
+	if _, err := ds.rw.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	hdr, err := readHeader(buf)
+	if err != nil {
+		return err
+	}
+	if hdr.hlen != ds.header.hlen || hdr.rlen != ds.header.rlen {
+		return errors.New("file structure changed by another process")
+	}
+	if hdr.rows != ds.header.rows {
+		rows := hdr.rows
+		if ds.header.rows < rows {
+			rows = ds.header.rows
+		}
+		for idx := range ds.pages {
+			if (idx+1)*ds.perPage > int(rows) {
+				ds.dropPage(idx)
+			}
+		}
+	}
+	ds.header.rows = hdr.rows
+	ds.header.changed = hdr.changed
+	return nil
+}
+
+func (ds *diskStorage) dropPage(idx int) {
+	if el, ok := ds.pages[idx]; ok {
+		ds.lru.Remove(el)
+		delete(ds.pages, idx)
+	}
+}
+
+func (ds *diskStorage) writeHeader() error {
+	return writeHeaderAt(ds.rw, ds.header)
+}
+
+// writeRowsTo writes all rows and EOF marker into specified writer
+// (bypassing the cache)
+func (ds *diskStorage) writeRowsTo(w io.Writer) error {
+	rows := io.NewSectionReader(ds.rw, ds.offset(0), ds.offset(int(ds.header.rows))-ds.offset(0))
+	n, err := io.Copy(w, rows)
+	if err != nil {
+		return err
+	}
+	if n != rows.Size() {
+		return errors.New("unexpected end of rows data")
+	}
+
+	_, err = w.Write([]byte{eof})
+	return err
+}
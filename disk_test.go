@@ -0,0 +1,129 @@
+package dbf3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// memRW presents growing in-memory ReaderWriterAt
+type memRW struct {
+	data []byte
+}
+
+func (m *memRW) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memRW) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if end := int(off) + len(p); end > len(m.data) {
+		m.data = append(m.data, make([]byte, end-len(m.data))...)
+	}
+	return copy(m.data[off:], p), nil
+}
+
+func TestOpenAt(t *testing.T) {
+	const rows = 3000 // several cache pages
+	rw := &memRW{data: saveTestFile(t, newTestFile(t, rows))}
+
+	f, err := OpenAt(rw, WithPageCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != rows {
+		t.Fatalf("expected %d rows, got %d", rows, f.Rows())
+	}
+	for _, idx := range []int{0, rows - 1, 1, rows / 2, 2} {
+		expectValue(t, f, idx, "NAME", "row"+strconv.Itoa(idx))
+	}
+
+	if err := f.Set(rows-1, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DelRow(0); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := f.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(idx, "NAME", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddField("OTHER", Logical, 0, 0); err == nil {
+		t.Fatal("expected error for schema change of disk-backed file")
+	}
+
+	// changes are written through
+	g, err := Open(bytes.NewReader(rw.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Rows() != rows+1 {
+		t.Fatalf("expected %d rows, got %d", rows+1, g.Rows())
+	}
+	expectValue(t, g, rows-1, "NAME", "changed")
+	expectValue(t, g, rows, "NAME", "new")
+	if deleted, _ := g.Deleted(0); !deleted {
+		t.Fatal("expected deleted row")
+	}
+}
+
+func TestOpenFileAt(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 10).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(fileName, WithPageCache(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := 0; idx < 10; idx += 2 {
+		if err := f.DelRow(idx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Pack(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 5 {
+		t.Fatalf("expected 5 rows, got %d", f.Rows())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Rows() != 5 {
+		t.Fatalf("expected 5 rows, got %d", g.Rows())
+	}
+	for idx := 0; idx < 5; idx++ {
+		expectValue(t, g, idx, "NAME", "row"+strconv.Itoa(idx*2+1))
+	}
+
+	st, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() != int64(g.HLen()+5*g.RLen()+1) {
+		t.Fatalf("unexpected file size %d", st.Size())
+	}
+}
@@ -1,6 +1,8 @@
 package dbf3
 
 import (
+	"io"
+	"strings"
 	"unicode"
 
 	"github.com/axgle/mahonia"
@@ -197,6 +199,48 @@ var charsets = map[string]string{
 	"949":   "windows-1252", // Temporary solution. Original charset currently not exists or not found yet
 }
 
+// cpgLangs maps code pages of .cpg files to language drivers
+var cpgLangs = map[string]LangID{
+	"437":   Lang1,
+	"850":   Lang2,
+	"1252":  Lang3,
+	"10000": Lang4,
+	"865":   Lang102,
+	"863":   Lang108,
+	"852":   Lang100,
+	"860":   Lang36,
+	"866":   Lang101,
+	"861":   Lang103,
+	"737":   Lang106,
+	"857":   Lang107,
+	"874":   Lang124,
+	"10007": Lang150,
+	"10029": Lang151,
+	"10006": Lang152,
+	"1250":  Lang200,
+	"1251":  Lang201,
+	"1254":  Lang202,
+	"1253":  Lang203,
+	"1257":  Lang204,
+}
+
+// readCPG reads code page from companion .cpg file
+// (e.g. "1251", "CP1251", "ANSI 1251" or "windows-1251")
+// and returns corresponding language driver
+func readCPG(r io.Reader) (LangID, bool) {
+	buf, err := io.ReadAll(io.LimitReader(r, 64))
+	if err != nil {
+		return LangDefault, false
+	}
+
+	cp := strings.ToUpper(strings.TrimSpace(string(buf)))
+	for _, prefix := range []string{"ANSI", "OEM", "WINDOWS-", "CP", "IBM"} {
+		cp = strings.TrimSpace(strings.TrimPrefix(cp, prefix))
+	}
+	lang, ok := cpgLangs[cp]
+	return lang, ok
+}
+
 func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
 		if s[i] > unicode.MaxASCII {
@@ -0,0 +1,415 @@
+package dbf3
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Expr presents compiled xBase expression,
+// such as index key or FOR filter
+//
+// Values of expression are presented by Go types:
+// string for character, float64 for numeric,
+// time.Time for date and bool for logical values
+type Expr interface {
+	// Eval evaluates expression against specified row
+	Eval(row Row) (value interface{}, err error)
+	// Match evaluates logical expression against specified row
+	Match(row Row) (ok bool, err error)
+	// String returns source text of expression
+	String() string
+}
+
+// Compile parses xBase expression, e.g. `UPPER(NAME)+DTOS(BIRTH)`
+// or `AMOUNT > 100 .AND. !DELETED()`
+func Compile(expr string) (Expr, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiledExpr{src: expr, root: root}, nil
+}
+
+type compiledExpr struct {
+	src  string
+	root node
+}
+
+func (e *compiledExpr) String() string { return e.src }
+
+func (e *compiledExpr) Eval(row Row) (interface{}, error) {
+	return e.root.eval(row)
+}
+
+func (e *compiledExpr) Match(row Row) (bool, error) {
+	val, err := e.root.eval(row)
+	if err != nil {
+		return false, err
+	}
+
+	ok, isBool := val.(bool)
+	if !isBool {
+		return false, errors.New("expression is not logical")
+	}
+	return ok, nil
+}
+
+// node presents node of expression tree
+type node interface {
+	eval(row Row) (interface{}, error)
+}
+
+type litNode struct {
+	val interface{}
+}
+
+func (n *litNode) eval(Row) (interface{}, error) { return n.val, nil }
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(row Row) (interface{}, error) {
+	if row == nil {
+		return nil, errors.New("no row to get field " + n.name)
+	}
+
+	val, err := fieldValue(row, n.name)
+	if err == nil {
+		return val, nil
+	}
+
+	// field names are case insensitive in xBase
+	if upper := strings.ToUpper(n.name); upper != n.name {
+		if val, uerr := fieldValue(row, upper); uerr == nil {
+			return val, nil
+		}
+	}
+	return nil, err
+}
+
+// rawRow presents row, which returns character values
+// padded to the field length (rows of this package)
+type rawRow interface {
+	rawChar(field string) (val string, char bool, err error)
+}
+
+// fieldValue returns value of field. As in xBase, character values
+// are not trimmed, so that keys like UPPER(NAME)+DTOS(BIRTH)
+// have fixed length
+func fieldValue(row Row, name string) (interface{}, error) {
+	if rr, ok := row.(rawRow); ok {
+		val, char, err := rr.rawChar(name)
+		if err != nil {
+			return nil, err
+		}
+		if char {
+			return val, nil
+		}
+	}
+	return row.Value(name)
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(row Row) (interface{}, error) {
+	val, err := n.x.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := val.(bool)
+	if !ok {
+		return nil, errors.New("operator .NOT. requires logical operand")
+	}
+	return !b, nil
+}
+
+type negNode struct {
+	x node
+}
+
+func (n *negNode) eval(row Row) (interface{}, error) {
+	val, err := n.x.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	num, ok := val.(float64)
+	if !ok {
+		return nil, errors.New("unary minus requires numeric operand")
+	}
+	return -num, nil
+}
+
+// logicNode presents .AND. and .OR. operators
+// (right operand is evaluated only when needed)
+type logicNode struct {
+	and  bool
+	l, r node
+}
+
+func (n *logicNode) eval(row Row) (interface{}, error) {
+	l, err := evalBool(n.l, row)
+	if err != nil {
+		return nil, err
+	}
+	if l != n.and {
+		return l, nil
+	}
+
+	return evalBool(n.r, row)
+}
+
+func evalBool(n node, row Row) (bool, error) {
+	val, err := n.eval(row)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := val.(bool)
+	if !ok {
+		return false, errors.New("logical operator requires logical operands")
+	}
+	return b, nil
+}
+
+type binaryNode struct {
+	op   string
+	l, r node
+}
+
+func (n *binaryNode) eval(row Row) (interface{}, error) {
+	l, err := n.l.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.r.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+":
+		return add(l, r)
+	case "-":
+		return sub(l, r)
+	case "*", "/", "%", "^":
+		return arith(n.op, l, r)
+	case "$":
+		ls, lok := l.(string)
+		rs, rok := r.(string)
+		if !lok || !rok {
+			return nil, errors.New("operator $ requires character operands")
+		}
+		return strings.Contains(rs, ls), nil
+	case "=", "<>":
+		eq, err := equal(l, r, false)
+		if err != nil {
+			return nil, err
+		}
+		return eq == (n.op == "="), nil
+	case "==":
+		return equal(l, r, true)
+	}
+
+	cmp, err := compare(l, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default: // ">="
+		return cmp >= 0, nil
+	}
+}
+
+type iifNode struct {
+	cond, then, els node
+}
+
+func (n *iifNode) eval(row Row) (interface{}, error) {
+	cond, err := evalBool(n.cond, row)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval(row)
+	}
+	return n.els.eval(row)
+}
+
+type callNode struct {
+	fn   *exprFunc
+	args []node
+}
+
+func (n *callNode) eval(row Row) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for idx := range n.args {
+		val, err := n.args[idx].eval(row)
+		if err != nil {
+			return nil, err
+		}
+		args[idx] = val
+	}
+
+	return n.fn.call(row, args)
+}
+
+func add(l, r interface{}) (interface{}, error) {
+	switch lv := l.(type) {
+	case string:
+		if rv, ok := r.(string); ok {
+			return lv + rv, nil
+		}
+	case float64:
+		switch rv := r.(type) {
+		case float64:
+			return lv + rv, nil
+		case time.Time:
+			return addDays(rv, lv), nil
+		}
+	case time.Time:
+		if rv, ok := r.(float64); ok {
+			return addDays(lv, rv), nil
+		}
+	}
+	return nil, errors.New("operator + type mismatch")
+}
+
+func sub(l, r interface{}) (interface{}, error) {
+	switch lv := l.(type) {
+	case string:
+		if rv, ok := r.(string); ok {
+			// trailing spaces of left operand moves to the end
+			trimmed := strings.TrimRight(lv, " ")
+			return trimmed + rv + lv[len(trimmed):], nil
+		}
+	case float64:
+		if rv, ok := r.(float64); ok {
+			return lv - rv, nil
+		}
+	case time.Time:
+		switch rv := r.(type) {
+		case float64:
+			return addDays(lv, -rv), nil
+		case time.Time:
+			return math.Round(lv.Sub(rv).Hours() / 24), nil
+		}
+	}
+	return nil, errors.New("operator - type mismatch")
+}
+
+func arith(op string, l, r interface{}) (interface{}, error) {
+	lv, lok := l.(float64)
+	rv, rok := r.(float64)
+	if !lok || !rok {
+		return nil, errors.New("operator " + op + " requires numeric operands")
+	}
+
+	switch op {
+	case "*":
+		return lv * rv, nil
+	case "/":
+		if rv == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lv / rv, nil
+	case "%":
+		if rv == 0 {
+			return nil, errors.New("division by zero")
+		}
+		// as in xBase, sign of result follows divisor
+		mod := math.Mod(lv, rv)
+		if mod != 0 && (mod < 0) != (rv < 0) {
+			mod += rv
+		}
+		return mod, nil
+	default: // "^"
+		return math.Pow(lv, rv), nil
+	}
+}
+
+// equal compares values for equality. Not exact comparison
+// of strings works like in xBase with SET EXACT OFF:
+// left string equals to right one if it starts with it
+func equal(l, r interface{}, exact bool) (bool, error) {
+	if ls, ok := l.(string); ok && !exact {
+		rs, ok := r.(string)
+		if !ok {
+			return false, errors.New("comparison type mismatch")
+		}
+		return strings.HasPrefix(ls, rs), nil
+	}
+
+	cmp, err := compare(l, r)
+	if err != nil {
+		return false, err
+	}
+	return cmp == 0, nil
+}
+
+func compare(l, r interface{}) (int, error) {
+	switch lv := l.(type) {
+	case string:
+		if rv, ok := r.(string); ok {
+			return strings.Compare(lv, rv), nil
+		}
+	case float64:
+		if rv, ok := r.(float64); ok {
+			switch {
+			case lv < rv:
+				return -1, nil
+			case lv > rv:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case time.Time:
+		if rv, ok := r.(time.Time); ok {
+			switch {
+			case lv.Before(rv):
+				return -1, nil
+			case lv.After(rv):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case bool:
+		if rv, ok := r.(bool); ok {
+			switch {
+			case lv == rv:
+				return 0, nil
+			case rv:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, errors.New("comparison type mismatch")
+}
+
+func addDays(d time.Time, days float64) time.Time {
+	if d.IsZero() {
+		return d
+	}
+	return d.AddDate(0, 0, int(days))
+}
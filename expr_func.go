@@ -0,0 +1,471 @@
+package dbf3
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exprFunc presents xBase function
+type exprFunc struct {
+	min, max int // arguments count
+	call     func(row Row, args []interface{}) (interface{}, error)
+}
+
+var exprFuncs = map[string]*exprFunc{
+	// character
+	"UPPER":     {1, 1, strFunc(strings.ToUpper)},
+	"LOWER":     {1, 1, strFunc(strings.ToLower)},
+	"TRIM":      {1, 1, strFunc(trimRight)},
+	"RTRIM":     {1, 1, strFunc(trimRight)},
+	"LTRIM":     {1, 1, strFunc(trimLeft)},
+	"ALLTRIM":   {1, 1, strFunc(strings.TrimSpace)},
+	"SUBSTR":    {2, 3, fnSubstr},
+	"LEFT":      {2, 2, fnLeft},
+	"RIGHT":     {2, 2, fnRight},
+	"LEN":       {1, 1, fnLen},
+	"AT":        {2, 2, fnAt},
+	"SPACE":     {1, 1, fnSpace},
+	"REPLICATE": {2, 2, fnReplicate},
+	"PADL":      {2, 3, fnPad(true)},
+	"PADR":      {2, 3, fnPad(false)},
+	"STRTRAN":   {3, 3, fnStrtran},
+	"STR":       {1, 3, fnStr},
+	"VAL":       {1, 1, fnVal},
+	// date
+	"DATE":  {0, 0, fnDate},
+	"DTOS":  {1, 1, fnDtos},
+	"DTOC":  {1, 1, fnDtoc},
+	"CTOD":  {1, 1, fnCtod},
+	"STOD":  {1, 1, fnStod},
+	"YEAR":  {1, 1, datePartFunc(func(d time.Time) int { return d.Year() })},
+	"MONTH": {1, 1, datePartFunc(func(d time.Time) int { return int(d.Month()) })},
+	"DAY":   {1, 1, datePartFunc(func(d time.Time) int { return d.Day() })},
+	"DOW":   {1, 1, datePartFunc(func(d time.Time) int { return int(d.Weekday()) + 1 })},
+	// numeric
+	"ABS":   {1, 1, numFunc(math.Abs)},
+	"INT":   {1, 1, numFunc(math.Trunc)},
+	"SQRT":  {1, 1, numFunc(math.Sqrt)},
+	"ROUND": {2, 2, fnRound},
+	"MOD":   {2, 2, fnMod},
+	"MAX":   {2, 2, fnMax},
+	"MIN":   {2, 2, fnMin},
+	// other
+	"EMPTY":   {1, 1, fnEmpty},
+	"DELETED": {0, 0, fnDeleted},
+	"RECNO":   {0, 0, fnRecno},
+}
+
+// lookupFunc finds function by name. As in xBase,
+// function name can be abbreviated to 4 characters
+func lookupFunc(name string) *exprFunc {
+	if fn, ok := exprFuncs[name]; ok {
+		return fn
+	}
+	if len(name) < 4 {
+		return nil
+	}
+
+	var found *exprFunc
+	for fname, fn := range exprFuncs {
+		if strings.HasPrefix(fname, name) {
+			if found != nil {
+				return nil // ambiguous
+			}
+			found = fn
+		}
+	}
+	return found
+}
+
+func argStr(args []interface{}, idx int) (string, error) {
+	s, ok := args[idx].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d must be character", idx+1)
+	}
+	return s, nil
+}
+
+func argNum(args []interface{}, idx int) (float64, error) {
+	n, ok := args[idx].(float64)
+	if !ok {
+		return 0, fmt.Errorf("argument %d must be numeric", idx+1)
+	}
+	return n, nil
+}
+
+func argDate(args []interface{}, idx int) (time.Time, error) {
+	d, ok := args[idx].(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("argument %d must be date", idx+1)
+	}
+	return d, nil
+}
+
+func strFunc(fn func(string) string) func(Row, []interface{}) (interface{}, error) {
+	return func(_ Row, args []interface{}) (interface{}, error) {
+		s, err := argStr(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func numFunc(fn func(float64) float64) func(Row, []interface{}) (interface{}, error) {
+	return func(_ Row, args []interface{}) (interface{}, error) {
+		n, err := argNum(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(n), nil
+	}
+}
+
+func datePartFunc(fn func(time.Time) int) func(Row, []interface{}) (interface{}, error) {
+	return func(_ Row, args []interface{}) (interface{}, error) {
+		d, err := argDate(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if d.IsZero() {
+			return float64(0), nil
+		}
+		return float64(fn(d)), nil
+	}
+}
+
+func trimRight(s string) string { return strings.TrimRight(s, " ") }
+func trimLeft(s string) string  { return strings.TrimLeft(s, " ") }
+
+func fnSubstr(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	from := clamp(start-1, len(s))
+	to := len(s)
+	if len(args) > 2 {
+		length, err := argNum(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		to = from + clamp(length, len(s)-from)
+	}
+	return s[from:to], nil
+}
+
+func fnLeft(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return s[:clamp(n, len(s))], nil
+}
+
+func fnRight(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return s[len(s)-clamp(n, len(s)):], nil
+}
+
+// clamp converts number to integer in range [0, max]
+// (NaN is converted to 0)
+func clamp(n float64, max int) int {
+	if !(n > 0) {
+		return 0
+	}
+	if n > float64(max) {
+		return max
+	}
+	return int(n)
+}
+
+func fnLen(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return float64(len(s)), nil
+}
+
+func fnAt(_ Row, args []interface{}) (interface{}, error) {
+	sub, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	s, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return float64(strings.Index(s, sub) + 1), nil
+}
+
+func fnSpace(_ Row, args []interface{}) (interface{}, error) {
+	n, err := argNum(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Repeat(" ", clamp(n, math.MaxUint16)), nil
+}
+
+func fnReplicate(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) > 0 && n > float64(math.MaxUint16/len(s)) {
+		return nil, errors.New("REPLICATE result is too long")
+	}
+	return strings.Repeat(s, clamp(n, math.MaxUint16)), nil
+}
+
+func fnPad(left bool) func(Row, []interface{}) (interface{}, error) {
+	return func(_ Row, args []interface{}) (interface{}, error) {
+		s, err := argStr(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := argNum(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		fill := " "
+		if len(args) > 2 {
+			if fill, err = argStr(args, 2); err != nil {
+				return nil, err
+			}
+			if len(fill) != 1 {
+				return nil, errors.New("fill argument must be single character")
+			}
+		}
+
+		length := clamp(n, math.MaxUint16)
+		if len(s) >= length {
+			if left {
+				return s[len(s)-length:], nil
+			}
+			return s[:length], nil
+		}
+		pad := strings.Repeat(fill, length-len(s))
+		if left {
+			return pad + s, nil
+		}
+		return s + pad, nil
+	}
+}
+
+func fnStrtran(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	from, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	to, err := argStr(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Replace(s, from, to, -1), nil
+}
+
+// fnStr formats number to string with specified length
+// (10 by default) and decimals (0 by default).
+// Too long result replaced by asterisks
+func fnStr(_ Row, args []interface{}) (interface{}, error) {
+	n, err := argNum(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	length, dec := 10, 0
+	if len(args) > 1 {
+		l, err := argNum(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		length = clamp(l, math.MaxUint8)
+	}
+	if len(args) > 2 {
+		d, err := argNum(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		dec = clamp(d, math.MaxUint8)
+	}
+
+	s := strconv.FormatFloat(n, 'f', dec, 64)
+	if len(s) > length {
+		return strings.Repeat("*", length), nil
+	}
+	return strings.Repeat(" ", length-len(s)) + s, nil
+}
+
+func fnVal(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// as in xBase, leading number is taken and the rest is ignored
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (isDigit(s[end]) || s[end] == '.' ||
+		end == 0 && (s[end] == '-' || s[end] == '+')) {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return float64(0), nil
+	}
+	return n, nil
+}
+
+func fnDate(Row, []interface{}) (interface{}, error) {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), nil
+}
+
+func fnDtos(_ Row, args []interface{}) (interface{}, error) {
+	d, err := argDate(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if d.IsZero() {
+		return "        ", nil
+	}
+	return d.Format(dateLayout), nil
+}
+
+func fnDtoc(_ Row, args []interface{}) (interface{}, error) {
+	d, err := argDate(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if d.IsZero() {
+		return "  /  /  ", nil
+	}
+	return d.Format("01/02/06"), nil
+}
+
+func fnCtod(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	d, _ := parseAmericanDate(s)
+	return d, nil
+}
+
+func fnStod(_ Row, args []interface{}) (interface{}, error) {
+	s, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseValue(Date, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return d, nil
+}
+
+// parseAmericanDate parses MM/DD/YY and MM/DD/YYYY dates
+func parseAmericanDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"1/2/2006", "1/2/06"} {
+		if d, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func fnRound(_ Row, args []interface{}) (interface{}, error) {
+	n, err := argNum(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	p := math.Pow(10, math.Trunc(dec))
+	return math.Round(n*p) / p, nil
+}
+
+func fnMod(_ Row, args []interface{}) (interface{}, error) {
+	return arith("%", args[0], args[1])
+}
+
+func fnMax(_ Row, args []interface{}) (interface{}, error) {
+	cmp, err := compare(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if cmp < 0 {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func fnMin(_ Row, args []interface{}) (interface{}, error) {
+	cmp, err := compare(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if cmp > 0 {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func fnEmpty(_ Row, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case string:
+		return strings.TrimSpace(v) == "", nil
+	case float64:
+		return v == 0, nil
+	case time.Time:
+		return v.IsZero(), nil
+	case bool:
+		return !v, nil
+	}
+	return true, nil
+}
+
+func fnDeleted(row Row, _ []interface{}) (interface{}, error) {
+	if row == nil {
+		return nil, errors.New("DELETED() requires row")
+	}
+	return row.Deleted(), nil
+}
+
+func fnRecno(row Row, _ []interface{}) (interface{}, error) {
+	if row == nil {
+		return nil, errors.New("RECNO() requires row")
+	}
+	return float64(row.Index() + 1), nil
+}
@@ -0,0 +1,433 @@
+package dbf3
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokenKind byte
+
+const (
+	tokEOF tokenKind = iota
+	tokNum
+	tokStr
+	tokDate
+	tokBool
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators sorted by length (longest first)
+var operators = []string{
+	".AND.", ".NOT.", ".OR.",
+	"**", "==", "<>", "!=", "<=", ">=", "->",
+	"+", "-", "*", "/", "%", "^", "=", "#", "<", ">", "$", "!", "(", ")", ",",
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(src); {
+		c := src[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			pos++
+		case isDigit(c) || c == '.' && pos+1 < len(src) && isDigit(src[pos+1]):
+			end := pos
+			for end < len(src) && isDigit(src[end]) {
+				end++
+			}
+			// decimal point followed by letter starts operator,
+			// e.g. AMOUNT>100.AND.OK
+			if end < len(src) && src[end] == '.' &&
+				(end+1 == len(src) || !unicode.IsLetter(rune(src[end+1]))) {
+				end++
+				for end < len(src) && isDigit(src[end]) {
+					end++
+				}
+			}
+			tokens = append(tokens, token{tokNum, src[pos:end], pos})
+			pos = end
+		case c == '\'' || c == '"' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(src[pos+1:], closing)
+			if end < 0 {
+				return nil, errors.New("unterminated string at " + strconv.Itoa(pos))
+			}
+			tokens = append(tokens, token{tokStr, src[pos+1 : pos+1+end], pos})
+			pos += end + 2
+		case c == '{':
+			end := strings.IndexByte(src[pos:], '}')
+			if end < 0 {
+				return nil, errors.New("unterminated date at " + strconv.Itoa(pos))
+			}
+			tokens = append(tokens, token{tokDate, src[pos+1 : pos+end], pos})
+			pos += end + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := pos
+			for end < len(src) && (src[end] == '_' || isDigit(src[end]) ||
+				unicode.IsLetter(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, src[pos:end], pos})
+			pos = end
+		default:
+			if len(src) >= pos+3 && src[pos+2] == '.' && c == '.' {
+				switch strings.ToUpper(src[pos+1 : pos+2]) {
+				case "T", "Y":
+					tokens = append(tokens, token{tokBool, "T", pos})
+					pos += 3
+					continue
+				case "F", "N":
+					tokens = append(tokens, token{tokBool, "F", pos})
+					pos += 3
+					continue
+				}
+			}
+
+			op := matchOperator(src[pos:])
+			if op == "" {
+				return nil, errors.New("unexpected character at " + strconv.Itoa(pos))
+			}
+			tokens = append(tokens, token{tokOp, normalizeOperator(op), pos})
+			pos += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(src)}), nil
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if len(s) >= len(op) && strings.EqualFold(s[:len(op)], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func normalizeOperator(op string) string {
+	switch op {
+	case "!", ".NOT.":
+		return "!"
+	case "#", "!=":
+		return "<>"
+	case "**":
+		return "^"
+	}
+	return strings.ToUpper(op)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(src string) (*parser, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("expected " + op)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(msg string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return errors.New(msg + " at end of expression")
+	}
+	return errors.New(msg + " at " + strconv.Itoa(t.pos))
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(".OR.") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &logicNode{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(".AND.") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &logicNode{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+	return p.parseRelation()
+}
+
+func (p *parser) parseRelation() (node, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("=", "==", "<>", "<", "<=", ">", ">=", "$") {
+		op := p.next().text
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next().text
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	l, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		op := p.next().text
+		r, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parsePower() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("^") {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: "^", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-", "+") {
+		neg := p.next().text == "-"
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if neg {
+			return &negNode{x}, nil
+		}
+		return x, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNum:
+		p.next()
+		num, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.New("invalid number at " + strconv.Itoa(t.pos))
+		}
+		return &litNode{num}, nil
+	case tokStr:
+		p.next()
+		return &litNode{t.text}, nil
+	case tokBool:
+		p.next()
+		return &litNode{t.text == "T"}, nil
+	case tokDate:
+		p.next()
+		d, err := parseDateLiteral(t.text)
+		if err != nil {
+			return nil, errors.New(err.Error() + " at " + strconv.Itoa(t.pos))
+		}
+		return &litNode{d}, nil
+	case tokIdent:
+		p.next()
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		if p.isOp("->") {
+			// alias->field, alias is ignored
+			p.next()
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, errors.New("expected field name at " + strconv.Itoa(field.pos))
+			}
+			return &fieldNode{field.text}, nil
+		}
+		return &fieldNode{t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, p.errorf("unexpected token")
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // (
+	var args []node
+	if !p.isOp(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	fname := strings.ToUpper(name.text)
+	if fname == "IIF" || fname == "IF" {
+		if len(args) != 3 {
+			return nil, errors.New("IIF requires 3 arguments at " + strconv.Itoa(name.pos))
+		}
+		return &iifNode{args[0], args[1], args[2]}, nil
+	}
+
+	fn := lookupFunc(fname)
+	if fn == nil {
+		return nil, errors.New("unknown function " + name.text + " at " + strconv.Itoa(name.pos))
+	}
+	if len(args) < fn.min || len(args) > fn.max {
+		return nil, errors.New("wrong arguments count for " + fname + " at " + strconv.Itoa(name.pos))
+	}
+	return &callNode{fn: fn, args: args}, nil
+}
+
+// parseDateLiteral parses {^YYYY-MM-DD}, {MM/DD/YYYY} and {} (blank date)
+func parseDateLiteral(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "/  /" {
+		return time.Time{}, nil
+	}
+	if strings.HasPrefix(s, "^") {
+		d, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(s[1:]), time.Local)
+		if err != nil {
+			return time.Time{}, errors.New("invalid date literal")
+		}
+		return d, nil
+	}
+	d, ok := parseAmericanDate(s)
+	if !ok {
+		return time.Time{}, errors.New("invalid date literal")
+	}
+	return d, nil
+}
@@ -0,0 +1,245 @@
+package dbf3
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExprRow(t *testing.T) Row {
+	f := New()
+	for _, fld := range []struct {
+		name string
+		typ  FieldType
+		len  byte
+		dec  byte
+	}{
+		{"NAME", Character, 10, 0},
+		{"AMOUNT", Numeric, 10, 2},
+		{"BIRTH", Date, 0, 0},
+		{"OK", Logical, 0, 0},
+	} {
+		if err := f.AddField(fld.name, fld.typ, fld.len, fld.dec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := f.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, val := range map[string]string{
+		"NAME": "bob", "AMOUNT": "150.5", "BIRTH": "19800101", "OK": "T",
+	} {
+		if err := f.Set(idx, name, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	row, err := f.Row(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestExprEval(t *testing.T) {
+	row := newExprRow(t)
+	for _, tc := range []struct {
+		src  string
+		want interface{}
+	}{
+		// fields
+		{"NAME", "bob       "},
+		{"name", "bob       "},
+		{"AMOUNT", 150.5},
+		{"BIRTH", time.Date(1980, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"OK", true},
+		{"T->NAME", "bob       "},
+		// character
+		{"UPPER(NAME)+DTOS(BIRTH)", "BOB       19800101"},
+		{"NAME-'!'", "bob!       "},
+		{"LEN(NAME)", float64(10)},
+		{"TRIM(NAME)+'!'", "bob!"},
+		{"SUBSTR(NAME, 2, 2)", "ob"},
+		{"SUBS(NAME, 2)", "ob       "},
+		{"LEFT('abc', 2)", "ab"},
+		{"RIGHT('abc', 2)", "bc"},
+		{"AT('o', NAME)", float64(2)},
+		{"PADL('ab', 4, '*')", "**ab"},
+		{"PADR('ab', 4)", "ab  "},
+		{"STRTRAN('abab', 'b', 'c')", "acac"},
+		{"STR(AMOUNT, 8, 1)", "   150.5"},
+		{"STR(123456, 3)", "***"},
+		{"VAL('12.5abc') * 2", float64(25)},
+		{"'bo' $ NAME .OR. .F.", true},
+		{"NAME = 'bob'", true},
+		{"NAME == 'bob'", false},
+		{"NAME <> 'bo'", false},
+		// numeric
+		{"1.5+1", 2.5},
+		{".5*2", float64(1)},
+		{"100. + 1", float64(101)},
+		{"2 ** 3 ^ 2", float64(64)},
+		{"MOD(7, 3)", float64(1)},
+		{"MOD(-7, 3)", float64(2)},
+		{"MOD(7, -3)", float64(-2)},
+		{"-7 % 3", float64(2)},
+		{"ROUND(2.345, 2)", 2.35},
+		{"INT(-2.5)", float64(-2)},
+		{"MAX(AMOUNT, 200)", float64(200)},
+		{"MIN(AMOUNT, 200)", 150.5},
+		// date
+		{"BIRTH + 30", time.Date(1980, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"{^2020-01-02} - {^2020-01-01}", float64(1)},
+		{"DTOC(CTOD('01/02/2003'))", "01/02/03"},
+		{"YEAR(BIRTH)", float64(1980)},
+		{"DTOS(STOD('20200102'))", "20200102"},
+		// logical
+		{"AMOUNT > 100 .AND. !DELETED()", true},
+		{"AMOUNT>100.AND.!DELETED()", true},
+		{"AMOUNT<100.OR.OK", true},
+		{".NOT. OK", false},
+		{"IIF(OK, 'y', 'n')", "y"},
+		{"EMPTY(NAME)", false},
+		{"EMPTY(SPACE(3))", true},
+		{"RECNO()", float64(1)},
+		// out of range arguments
+		{"SUBSTR('abc', 1, SQRT(-1))", ""},
+		{"SUBSTR('abc', 2, 10^19)", "bc"},
+		{"SUBSTR('abc', -10^19)", "abc"},
+		{"SUBSTR('abc', 10^19)", ""},
+		{"LEFT('abc', 10^19)", "abc"},
+		{"RIGHT('abc', SQRT(-1))", ""},
+		{"SPACE(-1)", ""},
+		{"LEN(SPACE(10^19))", float64(65535)},
+		{"REPLICATE('ab', SQRT(-1))", ""},
+		{"LEN(PADL('ab', 10^19))", float64(65535)},
+		{"LEN(STR(1, 10^19))", float64(255)},
+		{"STR(1, 4, SQRT(-1))", "   1"},
+	} {
+		expr, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("%s: compile error: %v", tc.src, err)
+			continue
+		}
+		got, err := expr.Eval(row)
+		if err != nil {
+			t.Errorf("%s: eval error: %v", tc.src, err)
+			continue
+		}
+		if d, ok := got.(time.Time); ok {
+			if !d.Equal(tc.want.(time.Time)) {
+				t.Errorf("%s: expected %v, got %v", tc.src, tc.want, d)
+			}
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: expected %#v, got %#v", tc.src, tc.want, got)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	row := newExprRow(t)
+	for _, tc := range []struct {
+		src        string
+		compileErr string
+		evalErr    string
+	}{
+		{src: "1 +", compileErr: "unexpected token at end of expression"},
+		{src: "(1", compileErr: "expected ) at end of expression"},
+		{src: "1 2", compileErr: "unexpected token at 2"},
+		{src: "1 @", compileErr: "unexpected character at 2"},
+		{src: "'abc", compileErr: "unterminated string at 0"},
+		{src: "{^2020-01-01", compileErr: "unterminated date at 0"},
+		{src: "FOO(1)", compileErr: "unknown function FOO at 0"},
+		{src: "1 + IIF(.T., 1)", compileErr: "IIF requires 3 arguments at 4"},
+		{src: "UNKNOWN", evalErr: "field not found"},
+		{src: "NAME + 1", evalErr: "operator + type mismatch"},
+		{src: "1 / 0", evalErr: "division by zero"},
+		{src: "MOD(1, 0)", evalErr: "division by zero"},
+		{src: "UPPER(1)", evalErr: "argument 1 must be character"},
+		{src: "1 .AND. .T.", evalErr: "logical operator requires logical operands"},
+		{src: "REPLICATE('ab', 10^19)", evalErr: "REPLICATE result is too long"},
+	} {
+		expr, err := Compile(tc.src)
+		if tc.compileErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.compileErr) {
+				t.Errorf("%s: expected compile error %q, got %v", tc.src, tc.compileErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: compile error: %v", tc.src, err)
+			continue
+		}
+		if _, err := expr.Eval(row); err == nil || !strings.Contains(err.Error(), tc.evalErr) {
+			t.Errorf("%s: expected eval error %q, got %v", tc.src, tc.evalErr, err)
+		}
+	}
+}
+
+func TestExprMatch(t *testing.T) {
+	row := newExprRow(t)
+
+	expr, err := Compile("AMOUNT > 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := expr.Match(row); err != nil || !ok {
+		t.Errorf("expected match, got %v, %v", ok, err)
+	}
+
+	expr, err = Compile("AMOUNT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expr.Match(row); err == nil {
+		t.Error("expected error for not logical expression")
+	}
+
+	// expressions without fields can be evaluated without row
+	expr, err = Compile("1 + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := expr.Eval(nil); err != nil || val != float64(3) {
+		t.Errorf("expected 3, got %v, %v", val, err)
+	}
+}
+
+func TestExprStreamRow(t *testing.T) {
+	f := New()
+	if err := f.AddField("NAME", Character, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := f.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(idx, "NAME", "ab"); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := f.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := NewReader(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := rd.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr, err := Compile("NAME+'|'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := expr.Eval(row); err != nil || val != "ab   |" {
+		t.Errorf("expected %q, got %q, %v", "ab   |", val, err)
+	}
+}
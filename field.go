@@ -2,6 +2,8 @@ package dbf3
 
 import (
 	"encoding/binary"
+	"errors"
+	"math"
 	"strings"
 )
 
@@ -18,6 +20,35 @@ func (f *field) Type() FieldType { return FieldType(f.descr.typ) }
 func (f *field) Len() int        { return f.len }
 func (f *field) Dec() byte       { return f.descr.dec }
 
+// value returns raw (not decoded) field value from row data
+func (f *field) value(row []byte) string {
+	return strings.TrimSpace(f.raw(row))
+}
+
+// raw returns value as is (padded to the field length)
+func (f *field) raw(row []byte) string {
+	return string(row[f.offset : f.offset+f.len])
+}
+
+// setValue puts encoded value into row data. Numeric values
+// are aligned to the right, other ones to the left
+func (f *field) setValue(row []byte, val string) {
+	buf := row[f.offset : f.offset+f.len]
+	if f.Type() == Numeric {
+		copy(buf[len(buf)-len(val):], val)
+		// add spaces to the start
+		for idx := 0; idx < len(buf)-len(val); idx++ {
+			buf[idx] = blank
+		}
+	} else {
+		copy(buf, val)
+		// add spaces to the end
+		for idx := len(val); idx < len(buf); idx++ {
+			buf[idx] = blank
+		}
+	}
+}
+
 type fieldDescr struct {
 	name [11]byte // name
 	typ  byte     // type
@@ -27,11 +58,16 @@ type fieldDescr struct {
 	_    [14]byte // reserved
 }
 
-func (fd *fieldDescr) readFrom(buf []byte) {
+func (fd *fieldDescr) readFrom(buf []byte) error {
+	if len(buf) < 32 {
+		return errors.New("field descriptor is too short")
+	}
+
 	copy(fd.name[:], buf[:11])
 	fd.typ = buf[11]
 	fd.len = buf[16]
 	fd.dec = buf[17]
+	return nil
 }
 
 func (fd fieldDescr) writeTo(buf []byte) {
@@ -41,7 +77,77 @@ func (fd fieldDescr) writeTo(buf []byte) {
 	buf[17] = fd.dec
 }
 
-func newField(dt fieldDescr, idx int, offset int) *field {
+func (fd *fieldDescr) nameString() string {
+	return strings.Trim(string(fd.name[:]), string([]byte{0}))
+}
+
+// newFieldDescr validates field parameters and creates field descriptor
+func newFieldDescr(name string, typ FieldType, length, dec byte) (fieldDescr, error) {
+	if !isASCII(name) {
+		return fieldDescr{}, errors.New("only ASCII chars allowed in field name")
+	}
+	name = strings.TrimSpace(name)
+	if len(name) > 11 {
+		return fieldDescr{}, errors.New("exceeded max field name length")
+	}
+
+	switch typ {
+	case Date:
+		length, dec = 8, 0
+	case Logical:
+		length, dec = 1, 0
+	case Numeric:
+		// TODO: check length and dec
+		if length-dec < 2 {
+			return fieldDescr{}, errors.New("decimal count must be lower at least 2 than length")
+		}
+	case Character:
+		flen := binary.LittleEndian.Uint16([]byte{length, dec})
+		if flen > math.MaxInt16 {
+			return fieldDescr{}, errors.New("exceeded max field length")
+		}
+	default:
+		return fieldDescr{}, errors.New("unsupported field type")
+	}
+
+	dt := fieldDescr{
+		len: length,
+		dec: dec,
+		typ: byte(typ),
+	}
+	copy(dt.name[:], name)
+	return dt, nil
+}
+
+// descrOf creates field descriptor from any Field implementation
+func descrOf(fld Field) (fieldDescr, error) {
+	length, dec := byte(fld.Len()), fld.Dec()
+	if fld.Type() == Character {
+		// length of character fields stored in both bytes
+		if fld.Len() > math.MaxUint16 {
+			return fieldDescr{}, errors.New("exceeded max field length")
+		}
+		dec = byte(fld.Len() >> 8)
+	} else if fld.Len() > math.MaxUint8 {
+		return fieldDescr{}, errors.New("exceeded max field length")
+	}
+	return newFieldDescr(fld.Name(), fld.Type(), length, dec)
+}
+
+// NewField creates field descriptor, which can be used
+// for creating files with specified fields
+func NewField(name string, typ FieldType, length, dec byte) (Field, error) {
+	dt, err := newFieldDescr(name, typ, length, dec)
+	if err != nil {
+		return nil, err
+	}
+	return newField(dt, 0, 1)
+}
+
+// maxFields presents max fields count, limited by header length
+const maxFields = (math.MaxUint16 - 33) / 32
+
+func newField(dt fieldDescr, idx int, offset int) (*field, error) {
 	var length uint16
 	switch FieldType(dt.typ) {
 	case Character:
@@ -53,11 +159,19 @@ func newField(dt fieldDescr, idx int, offset int) *field {
 	default:
 		length = uint16(dt.len)
 	}
+
+	if length == 0 {
+		return nil, errors.New("invalid field length")
+	}
+	if offset < 1 || offset+int(length) > math.MaxUint16 {
+		return nil, errors.New("exceeded max row length")
+	}
+
 	return &field{
 		descr:  dt,
-		name:   strings.Trim(string(dt.name[:]), string([]byte{0})),
+		name:   dt.nameString(),
 		idx:    idx,
 		offset: offset,
 		len:    int(length),
-	}
+	}, nil
 }
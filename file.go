@@ -1,12 +1,10 @@
 package dbf3
 
 import (
-	"encoding/binary"
 	"errors"
 	"io"
 	"math"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -24,6 +22,20 @@ type file struct {
 	fieldsIdx     map[string]int
 	converter     TextConverter
 	converterCtor TextConverterCtor
+
+	storage  Storage      // storage used by SaveFile
+	backup   bool         // keep previous version on SaveFile
+	disk     *diskStorage // rows storage of disk-backed file (nil if in memory)
+	lock     *locker      // locks of disk-backed file (nil if not supported)
+	journal  *journal     // journal of file updated in place (nil if disabled)
+	hooks    hooks        // callbacks of changes
+	dirty    dirtyRows    // rows changed since the last ResetDirty
+	history  *history     // undo/redo history (nil if disabled)
+	views    *views       // snapshots sharing rows data with file
+	view     *view        // rows data of snapshot (nil if file is not snapshot)
+	upd      *update      // changes tracking of file opened for update
+	closer   io.Closer    // underlying file (if opened by name)
+	readOnly bool
 }
 
 func (f *file) Rows() int          { return int(f.header.rows) }
@@ -65,15 +77,23 @@ func (f *file) NewRow() (int, error) {
 	if f.header.rows == math.MaxUint32 {
 		return 0, errors.New("cannot add more rows")
 	}
-	r := make([]byte, f.header.rlen+1)
+	unlock, err := f.lockRow(-1)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	r := make([]byte, f.header.rlen)
 	for idx := range r {
 		r[idx] = blank
 	}
-	r[f.header.rlen] = eof
-	f.data = append(f.data[:len(f.data)-1], r...)
-	f.header.rows++
+	if err := f.appendRow(r); err != nil {
+		return 0, err
+	}
 	f.header.updateChanged()
-	return int(f.header.rows) - 1, nil
+	f.record(newRowEdit())
+	f.fireNewRow(f.Rows() - 1)
+	return f.Rows() - 1, nil
 }
 
 func (f *file) DelRow(idx int) error {
@@ -81,12 +101,33 @@ func (f *file) DelRow(idx int) error {
 		return errors.New("out of range")
 	}
 
-	dtidx := idx * int(f.header.rlen)
-	if f.data[dtidx] == deleted {
+	unlock, err := f.lockRow(idx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := f.rowData(idx)
+	if err != nil {
+		return err
+	}
+	if data[0] == deleted {
 		return errors.New("already deleted")
 	}
-	f.data[dtidx] = deleted
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	buf[0] = deleted
+	var before []byte
+	if f.history != nil {
+		before = append([]byte(nil), data...)
+	}
+	if err := f.putRow(idx, buf); err != nil {
+		return err
+	}
 	f.header.updateChanged()
+	f.record(rowEdit(idx, before, buf))
+	f.fireDelRow(idx)
 	return nil
 }
 
@@ -95,19 +136,34 @@ func (f *file) Deleted(idx int) (bool, error) {
 		return false, errors.New("out of range")
 	}
 
-	dtidx := idx * int(f.header.rlen)
-	return f.data[dtidx] == deleted, nil
+	data, err := f.rowData(idx)
+	if err != nil {
+		return false, err
+	}
+	return data[0] == deleted, nil
 }
 
 func (f *file) Pack() error {
+	unlock, err := f.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	var deletedCount int
+	var deletedRows []int
+	var deletedData [][]byte
 	for row := 0; row < f.Rows(); row++ {
-		deleted, err := f.Deleted(row)
+		data, err := f.rowData(row)
 		if err != nil {
 			return err
 		}
-		if deleted {
+		if data[0] == deleted {
 			deletedCount++
+			if f.history != nil {
+				deletedRows = append(deletedRows, row)
+				deletedData = append(deletedData, append([]byte(nil), data...))
+			}
 			continue
 		}
 
@@ -115,91 +171,101 @@ func (f *file) Pack() error {
 			continue
 		}
 
-		dstidx := (row - deletedCount) * f.RLen()
-		srcidx := row * f.RLen()
-		copy(f.data[dstidx:], f.data[srcidx:srcidx+f.RLen()])
+		if err := f.putRow(row-deletedCount, data); err != nil {
+			return err
+		}
 	}
 
-	if deletedCount > 0 {
-		f.header.rows -= uint32(deletedCount)
-		f.data = f.data[:f.Rows()*f.RLen()+1]
-		f.data[len(f.data)-1] = eof
+	if deletedCount == 0 {
+		return nil
 	}
-
+	if err := f.truncateRows(f.Rows() - deletedCount); err != nil {
+		return err
+	}
+	f.record(packEdit(deletedRows, deletedData))
 	return nil
 }
 
 func (f *file) AddField(name string, typ FieldType, length, dec byte) error {
-	if !isASCII(name) {
-		return errors.New("only ASCII chars allowed in field name")
-	}
-	name = strings.TrimSpace(name)
-	if len(name) > 11 {
-		return errors.New("exceeded max field name length")
+	dt, err := newFieldDescr(name, typ, length, dec)
+	if err != nil {
+		return err
 	}
-	if _, exists := f.fieldsIdx[name]; exists {
+	if _, exists := f.fieldsIdx[dt.nameString()]; exists {
 		return errors.New("field already exists")
 	}
 
-	switch typ {
-	case Date:
-		return f.addField(name, typ, 8, 0)
-	case Logical:
-		return f.addField(name, typ, 1, 0)
-	case Numeric:
-		// TODO: check length and dec
-		if length-dec < 2 {
-			return errors.New("decimal count must be lower at least 2 than length")
-		}
-		return f.addField(name, typ, length, dec)
-	case Character:
-		flen := binary.LittleEndian.Uint16([]byte{length, dec})
-		if flen > math.MaxInt16 {
-			return errors.New("exceeded max field length")
-		}
-		return f.addField(name, typ, length, dec)
-	default:
-		return errors.New("unsupported field type")
+	if err := f.addField(dt); err != nil {
+		return err
 	}
+	f.record(addFieldEdit(len(f.fields)-1, dt))
+	f.fireSchemaChange()
+	return nil
 }
 
-func (f *file) addField(name string, typ FieldType, length, dec byte) error {
-	dt := fieldDescr{
-		len: length,
-		dec: dec,
-		typ: byte(typ),
-	}
-	copy(dt.name[:], name)
-	idx := len(f.fields)
-	var offset int
-	if idx > 0 {
-		offset = f.fields[idx-1].offset + f.fields[idx-1].Len()
-	}
-	fld := newField(dt, idx, offset)
-	f.fields = append(f.fields, fld)
-	f.fieldsIdx[fld.Name()] = idx
-	f.header.hlen += 32
-	f.header.rlen += uint16(fld.Len())
-	f.header.updateChanged()
+func (f *file) addField(dt fieldDescr) error {
+	return f.insertField(len(f.fields), dt, nil)
+}
 
-	if f.Rows() == 0 {
-		return nil
+// insertField inserts field at specified position. Values of field
+// are taken from column (values of all rows one after another),
+// or filled with blanks if column is nil
+func (f *file) insertField(pos int, dt fieldDescr, column []byte) error {
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	if f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+	if len(f.fields) >= maxFields {
+		return errors.New("exceeded max fields count")
 	}
 
-	buf := make([]byte, f.Rows()*fld.Len())
-	oldLen := len(f.data)
-	f.data = append(f.data, buf...)
-	f.data[len(f.data)-1] = f.data[oldLen-1] // move EOF
-	oldRLen := f.RLen() - fld.Len()
-	for row := f.Rows() - 1; row >= 0; row-- {
-		// move old row data
-		copy(f.data[row*f.RLen():], f.data[row*oldRLen:(row+1)*oldRLen])
-		// fill new field bytes with blank values
-		for idx = row*f.RLen() + oldRLen; idx < (row+1)*f.RLen(); idx++ {
-			f.data[idx] = blank
-		}
+	offset := 1 // fields starts after deletion flag
+	if pos > 0 {
+		offset = f.fields[pos-1].offset + f.fields[pos-1].Len()
+	}
+	fld, err := newField(dt, pos, offset)
+	if err != nil {
+		return err
+	}
+	if f.RLen()+fld.Len() > math.MaxUint16 {
+		return errors.New("exceeded max row length")
 	}
 
+	oldRLen := f.RLen()
+	rlen := oldRLen + fld.Len()
+	data := make([]byte, f.Rows()*rlen+1)
+	for row := 0; row < f.Rows(); row++ {
+		src := f.data[row*oldRLen : (row+1)*oldRLen]
+		dst := data[row*rlen : (row+1)*rlen]
+		copy(dst, src[:offset])
+		val := dst[offset : offset+fld.Len()]
+		if column != nil {
+			copy(val, column[row*fld.Len():])
+		} else {
+			for idx := range val {
+				val[idx] = blank
+			}
+		}
+		copy(dst[offset+fld.Len():], src[offset:])
+	}
+	data[len(data)-1] = eof
+
+	f.fields = append(f.fields, nil)
+	copy(f.fields[pos+1:], f.fields[pos:])
+	f.fields[pos] = fld
+	f.fieldsIdx[fld.Name()] = pos
+	for idx := pos + 1; idx < len(f.fields); idx++ {
+		f.fields[idx].idx = idx
+		f.fields[idx].offset += fld.Len()
+		f.fieldsIdx[f.fields[idx].name] = idx
+	}
+	f.header.hlen += 32
+	f.header.rlen = uint16(rlen)
+	f.header.updateChanged()
+	f.data = data
+	f.layoutChanged()
 	return nil
 }
 
@@ -208,7 +274,40 @@ func (f *file) DelField(field string) error {
 	if !ok {
 		return errors.New("field not found")
 	}
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	if f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+
+	fld := f.fields[fldIdx]
+	var column []byte
+	if f.history != nil {
+		column = f.column(fldIdx)
+	}
 
+	f.removeField(fldIdx)
+	f.record(delFieldEdit(fldIdx, fld.descr, column))
+	f.fireSchemaChange()
+	return nil
+}
+
+// column returns values of field with specified index
+// in all rows (one after another)
+func (f *file) column(fldIdx int) []byte {
+	fld := f.fields[fldIdx]
+	column := make([]byte, 0, f.Rows()*fld.Len())
+	for row := 0; row < f.Rows(); row++ {
+		offset := row*f.RLen() + fld.offset
+		column = append(column, f.data[offset:offset+fld.Len()]...)
+	}
+	return column
+}
+
+// removeField removes field with specified index
+// from in-memory file with all its values
+func (f *file) removeField(fldIdx int) {
 	fld := f.fields[fldIdx]
 	buf := make([]byte, len(f.data)-fld.Len()*f.Rows())
 	var bufOffset int
@@ -223,14 +322,19 @@ func (f *file) DelField(field string) error {
 		rowOffset += f.RLen()
 	}
 	buf[len(buf)-1] = eof
-	delete(f.fieldsIdx, field)
+	delete(f.fieldsIdx, fld.name)
 	copy(f.fields[fldIdx:], f.fields[fldIdx+1:])
 	f.fields = f.fields[:len(f.fields)-1]
+	for idx := fldIdx; idx < len(f.fields); idx++ {
+		f.fields[idx].idx = idx
+		f.fields[idx].offset -= fld.Len()
+		f.fieldsIdx[f.fields[idx].name] = idx
+	}
 	f.header.hlen -= 32
 	f.header.rlen -= uint16(fld.Len())
 	f.header.updateChanged()
 	f.data = buf
-	return nil
+	f.layoutChanged()
 }
 
 func (f *file) Get(row int, field string) (string, error) {
@@ -243,10 +347,47 @@ func (f *file) Get(row int, field string) (string, error) {
 		return "", errors.New("field not found")
 	}
 
+	data, err := f.rowData(row)
+	if err != nil {
+		return "", err
+	}
+
 	fld := f.fields[fldIdx]
-	offset := row*f.RLen() + fld.offset
-	val := strings.TrimSpace(string(f.data[offset : offset+fld.Len()]))
-	return f.converter.Decode(val)
+	return f.converter.Decode(fld.value(data))
+}
+
+// rawChar returns value of character field padded to the field length
+// (false for fields of other types)
+func (f *file) rawChar(row int, field string) (string, bool, error) {
+	if row < 0 || row >= f.Rows() {
+		return "", false, errors.New("out of range")
+	}
+
+	fldIdx, ok := f.fieldsIdx[field]
+	if !ok {
+		return "", false, errors.New("field not found")
+	}
+	fld := f.fields[fldIdx]
+	if fld.Type() != Character {
+		return "", false, nil
+	}
+
+	data, err := f.rowData(row)
+	if err != nil {
+		return "", false, err
+	}
+
+	val, err := f.converter.Decode(fld.raw(data))
+	return val, true, err
+}
+
+func (f *file) Value(row int, field string) (interface{}, error) {
+	val, err := f.Get(row, field)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseValue(f.fields[f.fieldsIdx[field]].Type(), val)
 }
 
 func (f *file) Set(row int, field, value string) error {
@@ -271,21 +412,35 @@ func (f *file) Set(row int, field, value string) error {
 
 	//TODO: types check
 
-	offset := row*f.RLen() + fld.offset
-	if fld.Type() == Numeric {
-		copy(f.data[offset+fld.Len()-len(cval):], []byte(cval))
-		// add spaces to the start
-		for idx := offset; idx < offset+fld.Len()-len(cval); idx++ {
-			f.data[idx] = blank
-		}
-	} else {
-		copy(f.data[offset:], []byte(cval))
-		// add spaces to the end
-		for idx := len(cval) + offset; idx < offset+fld.Len(); idx++ {
-			f.data[idx] = blank
-		}
+	unlock, err := f.lockRow(row)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := f.rowData(row)
+	if err != nil {
+		return err
+	}
+
+	var old string
+	if len(f.hooks.set) > 0 {
+		old, _ = f.converter.Decode(fld.value(data))
+	}
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	fld.setValue(buf, cval)
+	var before []byte
+	if f.history != nil {
+		before = append([]byte(nil), data...)
+	}
+	if err := f.putRow(row, buf); err != nil {
+		return err
 	}
 	f.header.updateChanged()
+	f.record(rowEdit(row, before, buf))
+	f.fireSet(row, field, old, value)
 	return nil
 }
 
@@ -302,13 +457,19 @@ func (f *file) Save(w io.Writer) error {
 	}
 
 	// header block terminator
-	buf[f.HLen()-1] = hterm
+	buf[32+len(f.fields)*32] = hterm
 
 	if _, err := w.Write(buf); err != nil {
 		return err
 	}
 
 	// write rows
+	if f.view != nil {
+		return f.view.writeTo(w)
+	}
+	if f.disk != nil {
+		return f.disk.writeRowsTo(w)
+	}
 	if _, err := w.Write(f.data); err != nil {
 		return err
 	}
@@ -317,11 +478,159 @@ func (f *file) Save(w io.Writer) error {
 }
 
 func (f *file) SaveFile(fileName string) error {
-	file, err := os.Create(fileName)
+	if f.savesOpened(fileName) {
+		// replaced file would stay opened, so later changes would be lost
+		return errors.New("file is opened for update, changes must be saved by Flush")
+	}
+	if f.backup {
+		if err := backupFile(f.storage, fileName); err != nil {
+			return err
+		}
+	}
+
+	return saveAtomic(f.storage, fileName, fileName, f.Save)
+}
+
+// savesOpened checks if file name refers to the file opened
+// for update or disk-backed file
+func (f *file) savesOpened(fileName string) bool {
+	fd, ok := f.closer.(*os.File)
+	if !ok || f.storage != OSStorage {
+		return false
+	}
+	st, err := os.Stat(fileName)
 	if err != nil {
+		return false
+	}
+	fst, err := fd.Stat()
+	return err == nil && os.SameFile(st, fst)
+}
+
+func (f *file) Flush() error {
+	var err error
+	switch {
+	case f.disk != nil:
+		err = f.disk.writeHeader()
+	case f.upd != nil:
+		err = f.upd.flush(f)
+	}
+	if err != nil || f.journal == nil {
+		return err
+	}
+	return f.journal.checkpoint()
+}
+
+func (f *file) Close() error {
+	var err error
+	if !f.readOnly {
+		err = f.Flush()
+	}
+	if f.lock != nil {
+		if uerr := f.lock.unlock(); err == nil {
+			err = uerr
+		}
+	}
+	if f.journal != nil {
+		// journal is kept to roll back unflushed changes
+		f.journal.close()
+	}
+	if f.closer != nil && f.view == nil {
+		// snapshots must not refer to closed file
+		if derr := f.detachViews(); err == nil {
+			err = derr
+		}
+	}
+	if f.closer != nil {
+		if cerr := f.closer.Close(); err == nil {
+			err = cerr
+		}
+		f.closer = nil
+	}
+	return err
+}
+
+// rowData returns data of row with specified index.
+// Returned slice must not be modified
+func (f *file) rowData(idx int) ([]byte, error) {
+	if f.view != nil {
+		buf := make([]byte, f.RLen())
+		return buf, f.view.readAt(buf, int64(idx)*int64(f.RLen()))
+	}
+	if f.disk != nil {
+		return f.disk.row(idx)
+	}
+
+	offset := idx * f.RLen()
+	return f.data[offset : offset+f.RLen()], nil
+}
+
+// putRow replaces data of row with specified index
+func (f *file) putRow(idx int, data []byte) error {
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	offset := int64(idx) * int64(f.RLen())
+	if err := f.preserve(offset, offset+int64(f.RLen())); err != nil {
 		return err
 	}
-	defer file.Close()
+	if f.disk != nil {
+		if err := f.disk.writeRow(idx, data); err != nil {
+			return err
+		}
+		f.dirty.mark(idx)
+		return nil
+	}
 
-	return f.Save(file)
+	copy(f.data[idx*f.RLen():], data)
+	if f.upd != nil {
+		f.upd.markRow(idx)
+	}
+	f.dirty.mark(idx)
+	return nil
+}
+
+// appendRow adds row with specified data to the end of file
+func (f *file) appendRow(data []byte) error {
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	offset := int64(f.Rows()) * int64(f.RLen())
+	if err := f.preserve(offset, offset+int64(len(data))+1); err != nil {
+		return err
+	}
+	if f.disk != nil {
+		if err := f.disk.appendRow(data); err != nil {
+			return err
+		}
+		f.dirty.mark(f.Rows() - 1)
+		return nil
+	}
+
+	f.data = append(f.data[:len(f.data)-1], data...)
+	f.data = append(f.data, eof)
+	f.header.rows++
+	if f.upd != nil {
+		f.upd.markRow(f.Rows() - 1)
+	}
+	f.dirty.mark(f.Rows() - 1)
+	return nil
+}
+
+// truncateRows removes rows after specified count
+func (f *file) truncateRows(rows int) error {
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	if err := f.preserve(int64(rows)*int64(f.RLen()), math.MaxInt64); err != nil {
+		return err
+	}
+	f.dirty.truncate(rows)
+	if f.disk != nil {
+		return f.disk.truncate(rows)
+	}
+
+	f.header.rows = uint32(rows)
+	f.data = f.data[:rows*f.RLen()+1]
+	f.data[len(f.data)-1] = eof
+	return nil
 }
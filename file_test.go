@@ -0,0 +1,94 @@
+package dbf3
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestDelField(t *testing.T) {
+	f := New()
+	for _, name := range []string{"A", "B", "C"} {
+		if err := f.AddField(name, Character, 3, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx, err := f.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"A": "aaa", "B": "bbb", "C": "ccc"} {
+		if err := f.Set(idx, name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.DelField("A"); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := f.Fields()
+	if len(fields) != 2 || fields[0].Name() != "B" || fields[1].Name() != "C" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	for name, want := range map[string]string{"B": "bbb", "C": "ccc"} {
+		value, err := f.Get(idx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != want {
+			t.Errorf("field %s: expected %q, got %q", name, want, value)
+		}
+	}
+	if _, err := f.Get(idx, "A"); err == nil {
+		t.Error("expected error for deleted field")
+	}
+}
+
+// newTestFile creates file with NAME (C10) and AMOUNT (N10.2) fields
+// and rows named "row<idx>" with amount "<idx>.5"
+func newTestFile(t *testing.T, rows int, opts ...Option) File {
+	t.Helper()
+	f := New(opts...)
+	if err := f.AddField("NAME", Character, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddField("AMOUNT", Numeric, 10, 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < rows; i++ {
+		idx, err := f.NewRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(idx, "NAME", "row"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(idx, "AMOUNT", strconv.Itoa(i)+".5"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// saveTestFile saves file into memory
+func saveTestFile(t *testing.T, f File) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := f.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// expectValue checks value of field
+func expectValue(t *testing.T, f File, row int, field, want string) {
+	t.Helper()
+	got, err := f.Get(row, field)
+	if err != nil {
+		t.Fatalf("row %d, field %s: %v", row, field, err)
+	}
+	if got != want {
+		t.Fatalf("row %d, field %s: expected %q, got %q", row, field, want, got)
+	}
+}
@@ -0,0 +1,68 @@
+package dbf3
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// OpenFS opens DBF from file system. Base names of the table and its
+// companion .cpg file are matched case insensitively (e.g. FOO.DBF
+// and foo.cpg), directories must match exactly. Language driver is
+// taken from companion .cpg file, if it's not specified by file header
+// or options. Other companion files (e.g. .dbt memo or index files)
+// are not read, since memo fields and indexes are not supported
+func OpenFS(fsys fs.FS, name string, opts ...Option) (File, error) {
+	name, err := lookupFS(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if lang, ok := readCPGFS(fsys, companionName(name, ".cpg")); ok {
+		opts = append(opts, withCPGLang(lang))
+	}
+
+	return Open(file, opts...)
+}
+
+// readCPGFS reads language driver from companion .cpg file
+func readCPGFS(fsys fs.FS, name string) (LangID, bool) {
+	name, err := lookupFS(fsys, name)
+	if err != nil {
+		return LangDefault, false
+	}
+
+	r, err := fsys.Open(name)
+	if err != nil {
+		return LangDefault, false
+	}
+	defer r.Close()
+
+	return readCPG(r)
+}
+
+// lookupFS finds file with specified name,
+// if it's not found, the name is matched case insensitively
+func lookupFS(fsys fs.FS, name string) (string, error) {
+	if _, err := fs.Stat(fsys, name); err == nil {
+		return name, nil
+	}
+
+	dir, base := path.Split(name)
+	entries, err := fs.ReadDir(fsys, path.Clean(dir))
+	if err != nil {
+		return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(entry.Name(), base) {
+			return dir + entry.Name(), nil
+		}
+	}
+	return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
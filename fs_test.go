@@ -0,0 +1,71 @@
+package dbf3
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenFS(t *testing.T) {
+	table := saveTestFile(t, newTestFile(t, 2))
+	withLang := saveTestFile(t, newTestFile(t, 1, WithLang(Lang38)))
+	fsys := fstest.MapFS{
+		"FOO.DBF":           {Data: table},
+		"foo.Cpg":           {Data: []byte("cp1251")},
+		"data/bar.dbf":      {Data: table},
+		"data/lang.dbf":     {Data: withLang},
+		"data/lang.cpg":     {Data: []byte("1251")},
+		"data/nocpg/x.dbf":  {Data: table},
+		"data/nocpg/readme": {Data: []byte("readme")},
+	}
+
+	for _, tc := range []struct {
+		name string
+		lang LangID
+	}{
+		{"foo.dbf", Lang201},
+		{"FOO.DBF", Lang201},
+		{"data/BAR.DBF", LangDefault},
+		{"data/lang.dbf", Lang38}, // language of header takes precedence
+		{"data/nocpg/x.dbf", LangDefault},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := OpenFS(fsys, tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Lang() != tc.lang {
+				t.Fatalf("expected language %d, got %d", tc.lang, f.Lang())
+			}
+			expectValue(t, f, 0, "NAME", "row0")
+		})
+	}
+
+	if _, err := OpenFS(fsys, "missing.dbf"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestReadCPG(t *testing.T) {
+	for _, tc := range []struct {
+		cpg  string
+		lang LangID
+		ok   bool
+	}{
+		{"1251", Lang201, true},
+		{"CP1251", Lang201, true},
+		{"ANSI 1251\r\n", Lang201, true},
+		{"windows-1251", Lang201, true},
+		{"OEM 866", Lang101, true},
+		{"ibm850", Lang2, true},
+		{"UTF-8", LangDefault, false},
+		{"", LangDefault, false},
+	} {
+		lang, ok := readCPG(strings.NewReader(tc.cpg))
+		if lang != tc.lang || ok != tc.ok {
+			t.Errorf("%q: expected %d, %v, got %d, %v", tc.cpg, tc.lang, tc.ok, lang, ok)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dbf3
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func fuzzSeed() []byte {
+	f := New()
+	f.AddField("NAME", Character, 10, 0)
+	f.AddField("AMOUNT", Numeric, 8, 2)
+	f.AddField("BIRTH", Date, 0, 0)
+	f.AddField("OK", Logical, 0, 0)
+	for _, values := range [][]string{
+		{"first", "1.5", "20200102", "T"},
+		{"second", "-20.25", "", "F"},
+	} {
+		idx, _ := f.NewRow()
+		f.Set(idx, "NAME", values[0])
+		f.Set(idx, "AMOUNT", values[1])
+		f.Set(idx, "BIRTH", values[2])
+		f.Set(idx, "OK", values[3])
+	}
+	f.DelRow(1)
+
+	var buf bytes.Buffer
+	f.Save(&buf)
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, f File) [][]string {
+	var rows [][]string
+	for idx := 0; idx < f.Rows(); idx++ {
+		var values []string
+		for _, fld := range f.Fields() {
+			val, err := f.Get(idx, fld.Name())
+			if err != nil {
+				t.Fatalf("get row %d field %s: %v", idx, fld.Name(), err)
+			}
+			f.Value(idx, fld.Name())
+			values = append(values, val)
+		}
+		rows = append(rows, values)
+	}
+	return rows
+}
+
+// fuzzSeedGap returns seed with extra bytes between header and data
+func fuzzSeedGap() []byte {
+	seed := fuzzSeed()
+	hlen := int(seed[8])
+	data := append([]byte(nil), seed[:hlen]...)
+	data = append(data, 0, 0, 0)
+	data = append(data, seed[hlen:]...)
+	data[8] += 3
+	return data
+}
+
+func FuzzOpen(f *testing.F) {
+	seed := fuzzSeed()
+	f.Add(seed)
+	f.Add(seed[:len(seed)-1])
+	f.Add(seed[:40])
+	f.Add(fuzzSeedGap())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := Open(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		rows := readAll(t, file)
+
+		var buf bytes.Buffer
+		if err := file.Save(&buf); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+
+		reopened, err := Open(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("open saved: %v", err)
+		}
+		if reopened.Rows() != file.Rows() || len(reopened.Fields()) != len(file.Fields()) {
+			t.Fatalf("layout changed after save")
+		}
+
+		again := readAll(t, reopened)
+		for idx := range rows {
+			for fld := range rows[idx] {
+				if rows[idx][fld] != again[idx][fld] {
+					t.Fatalf("row %d field %d changed after save", idx, fld)
+				}
+			}
+		}
+	})
+}
+
+func FuzzOpenRecovery(f *testing.F) {
+	seed := fuzzSeed()
+	f.Add(seed)
+	f.Add(seed[:len(seed)-3])
+	f.Add(seed[:40])
+	f.Add(fuzzSeedGap())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := Open(bytes.NewReader(data), WithRecovery(nil))
+		if err != nil {
+			return
+		}
+
+		readAll(t, file)
+
+		var buf bytes.Buffer
+		if err := file.Save(&buf); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		if _, err := Open(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("open saved: %v", err)
+		}
+	})
+}
+
+func FuzzReader(f *testing.F) {
+	seed := fuzzSeed()
+	f.Add(seed)
+	f.Add(seed[:len(seed)-3])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		for {
+			row, err := r.Read()
+			if err != nil {
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					t.Fatalf("read: %v", err)
+				}
+				return
+			}
+			for _, fld := range r.Fields() {
+				if _, err := row.Get(fld.Name()); err != nil {
+					t.Fatalf("get field %s: %v", fld.Name(), err)
+				}
+				row.Value(fld.Name())
+			}
+		}
+	})
+}
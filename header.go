@@ -2,29 +2,55 @@ package dbf3
 
 import (
 	"encoding/binary"
+	"errors"
 	"time"
 )
 
 type header struct {
-	signature byte     // signature
-	changed   [3]byte  // last modification date
-	rows      uint32   // rows count
-	hlen      uint16   // header length
-	rlen      uint16   // row length
-	_         [17]byte // reserved
-	lang      byte     // language driver ID
-	_         [2]byte  // reserved
+	signature   byte     // signature
+	changed     [3]byte  // last modification date
+	rows        uint32   // rows count
+	hlen        uint16   // header length
+	rlen        uint16   // row length
+	_           [2]byte  // reserved
+	transaction byte     // incomplete transaction flag
+	_           [14]byte // reserved
+	lang        byte     // language driver ID
+	_           [2]byte  // reserved
 }
 
-func readHeader(buf []byte) header {
+func newHeader(lang LangID) header {
+	h := header{
+		signature: 0x03, // dbase 3 without DBT
+		hlen:      33,   // header + terminator
+		rlen:      1,    // no fields + deletion flag
+		lang:      byte(lang),
+	}
+	h.updateChanged()
+	return h
+}
+
+func readHeader(buf []byte) (header, error) {
 	var h header
+	if len(buf) < 32 {
+		return h, errors.New("header is too short")
+	}
+
 	h.signature = buf[0]
 	copy(h.changed[:], buf[1:])
 	h.rows = binary.LittleEndian.Uint32(buf[4:])
 	h.hlen = binary.LittleEndian.Uint16(buf[8:])
 	h.rlen = binary.LittleEndian.Uint16(buf[10:])
+	h.transaction = buf[14]
 	h.lang = buf[29]
-	return h
+
+	if h.hlen < 33 {
+		return h, errors.New("invalid header length")
+	}
+	if h.rlen < 1 {
+		return h, errors.New("invalid row length")
+	}
+	return h, nil
 }
 
 func (h *header) writeTo(buf []byte) {
@@ -33,6 +59,7 @@ func (h *header) writeTo(buf []byte) {
 	binary.LittleEndian.PutUint32(buf[4:], h.rows)
 	binary.LittleEndian.PutUint16(buf[8:], h.hlen)
 	binary.LittleEndian.PutUint16(buf[10:], h.rlen)
+	buf[14] = h.transaction
 	buf[29] = h.lang
 }
 
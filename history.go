@@ -0,0 +1,312 @@
+package dbf3
+
+import "errors"
+
+// history presents undo/redo history of edits
+type history struct {
+	limit   int // max size of edits data (unlimited if not positive)
+	size    int
+	edits   []edit
+	pos     int            // edits before pos are done, edits after pos are undone
+	marks   map[string]int // positions of named checkpoints
+	replays bool           // edit is undone or redone (so it's not recorded)
+}
+
+// editOverhead presents approximate size of edit without data
+// (closures and captured descriptors), so edits without data
+// (e.g. NewRow or RenameField) are limited too
+const editOverhead = 64
+
+// edit presents recorded edit with its inverse
+type edit struct {
+	undo func(f *file) error
+	redo func(f *file) error
+	size int // approximate size of edit data
+}
+
+func newHistory(limit int) *history {
+	return &history{limit: limit, marks: make(map[string]int)}
+}
+
+// record adds edit into history, dropping undone edits
+// and the oldest edits exceeding the memory limit
+func (f *file) record(e edit) {
+	h := f.history
+	if h == nil || h.replays {
+		return
+	}
+
+	h.discard(h.pos)
+	e.size += editOverhead
+	h.edits = append(h.edits, e)
+	h.pos++
+	h.size += e.size
+
+	for h.limit > 0 && h.size > h.limit && len(h.edits) > 0 {
+		h.size -= h.edits[0].size
+		h.edits[0] = edit{}
+		h.edits = h.edits[1:]
+		h.pos--
+		for name, pos := range h.marks {
+			if pos == 0 {
+				delete(h.marks, name)
+			} else {
+				h.marks[name] = pos - 1
+			}
+		}
+	}
+}
+
+// discard drops edits after specified position (without undoing them)
+func (h *history) discard(pos int) {
+	if h == nil || pos >= len(h.edits) {
+		return
+	}
+
+	for idx := pos; idx < len(h.edits); idx++ {
+		h.size -= h.edits[idx].size
+		h.edits[idx] = edit{}
+	}
+	h.edits = h.edits[:pos]
+	if h.pos > pos {
+		h.pos = pos
+	}
+	for name, mark := range h.marks {
+		if mark > pos {
+			delete(h.marks, name)
+		}
+	}
+}
+
+// len returns position of history (nil-safe)
+func (h *history) len() int {
+	if h == nil {
+		return 0
+	}
+	return h.pos
+}
+
+func (f *file) Undo() error {
+	h := f.history
+	if h == nil {
+		return errors.New("history is not enabled")
+	}
+	if h.pos == 0 {
+		return errors.New("nothing to undo")
+	}
+
+	h.replays = true
+	err := h.edits[h.pos-1].undo(f)
+	h.replays = false
+	if err != nil {
+		return err
+	}
+	h.pos--
+	return nil
+}
+
+func (f *file) Redo() error {
+	h := f.history
+	if h == nil {
+		return errors.New("history is not enabled")
+	}
+	if h.pos == len(h.edits) {
+		return errors.New("nothing to redo")
+	}
+
+	h.replays = true
+	err := h.edits[h.pos].redo(f)
+	h.replays = false
+	if err != nil {
+		return err
+	}
+	h.pos++
+	return nil
+}
+
+func (f *file) Checkpoint(name string) error {
+	if f.history == nil {
+		return errors.New("history is not enabled")
+	}
+	f.history.marks[name] = f.history.pos
+	return nil
+}
+
+func (f *file) RestoreCheckpoint(name string) error {
+	if f.history == nil {
+		return errors.New("history is not enabled")
+	}
+	pos, ok := f.history.marks[name]
+	if !ok {
+		return errors.New("checkpoint not found")
+	}
+
+	for f.history.pos > pos {
+		if err := f.Undo(); err != nil {
+			return err
+		}
+	}
+	for f.history.pos < pos {
+		if err := f.Redo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowEdit creates edit of row data
+func rowEdit(idx int, before, after []byte) edit {
+	return edit{
+		undo: func(f *file) error { return f.replaceRow(idx, before) },
+		redo: func(f *file) error { return f.replaceRow(idx, after) },
+		size: len(before) + len(after),
+	}
+}
+
+// replaceRow replaces data of row and calls OnSet callbacks
+// for changed values
+func (f *file) replaceRow(idx int, data []byte) error {
+	unlock, err := f.lockRow(idx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := f.rowData(idx)
+	if err != nil {
+		return err
+	}
+	old := append([]byte(nil), cur...)
+	if err := f.putRow(idx, data); err != nil {
+		return err
+	}
+	f.header.updateChanged()
+
+	if len(f.hooks.set) == 0 {
+		return nil
+	}
+	for _, fld := range f.fields {
+		oldVal, newVal := fld.value(old), fld.value(data)
+		if oldVal == newVal {
+			continue
+		}
+		oldVal, _ = f.converter.Decode(oldVal)
+		newVal, _ = f.converter.Decode(newVal)
+		f.fireSet(idx, fld.Name(), oldVal, newVal)
+	}
+	return nil
+}
+
+// newRowEdit creates edit of row creation
+func newRowEdit() edit {
+	return edit{
+		undo: func(f *file) error {
+			unlock, err := f.lockTable()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return f.truncateRows(f.Rows() - 1)
+		},
+		redo: func(f *file) error {
+			_, err := f.NewRow()
+			return err
+		},
+	}
+}
+
+// packEdit creates edit of removing rows with specified (sorted)
+// indexes and data
+func packEdit(rows []int, data [][]byte) edit {
+	size := 0
+	for idx := range data {
+		size += len(data[idx])
+	}
+	return edit{
+		undo: func(f *file) error {
+			unlock, err := f.lockTable()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return f.insertRows(rows, data)
+		},
+		redo: func(f *file) error {
+			unlock, err := f.lockTable()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return f.removeRows(rows)
+		},
+		size: size,
+	}
+}
+
+// insertRows inserts rows with specified (sorted) indexes and data
+func (f *file) insertRows(rows []int, data [][]byte) error {
+	total := f.Rows() + len(rows)
+	empty := make([]byte, f.RLen())
+	for f.Rows() < total {
+		if err := f.appendRow(empty); err != nil {
+			return err
+		}
+	}
+
+	src := total - len(rows) - 1
+	ins := len(rows) - 1
+	for dst := total - 1; ins >= 0; dst-- {
+		if rows[ins] == dst {
+			if err := f.putRow(dst, data[ins]); err != nil {
+				return err
+			}
+			ins--
+			continue
+		}
+
+		row, err := f.rowData(src)
+		if err != nil {
+			return err
+		}
+		if err := f.putRow(dst, append([]byte(nil), row...)); err != nil {
+			return err
+		}
+		src--
+	}
+	f.header.updateChanged()
+	return nil
+}
+
+// addFieldEdit creates edit of field adding at specified position
+func addFieldEdit(pos int, dt fieldDescr) edit {
+	return edit{
+		undo: func(f *file) error {
+			f.removeField(pos)
+			f.fireSchemaChange()
+			return nil
+		},
+		redo: func(f *file) error {
+			if err := f.insertField(pos, dt, nil); err != nil {
+				return err
+			}
+			f.fireSchemaChange()
+			return nil
+		},
+	}
+}
+
+// delFieldEdit creates edit of field deleting. Values of field
+// are kept in column (values of all rows one after another)
+func delFieldEdit(pos int, dt fieldDescr, column []byte) edit {
+	return edit{
+		undo: func(f *file) error {
+			if err := f.insertField(pos, dt, column); err != nil {
+				return err
+			}
+			f.fireSchemaChange()
+			return nil
+		},
+		redo: func(f *file) error { return f.DelField(dt.nameString()) },
+		size: len(column),
+	}
+}
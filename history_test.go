@@ -0,0 +1,181 @@
+package dbf3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	f := newTestFile(t, 3, WithHistory(0))
+	// rows of test file are created with history
+	for f.Undo() == nil {
+	}
+	if f.Rows() != 0 || len(f.Fields()) != 0 {
+		t.Fatalf("expected empty file, got %d rows and %d fields", f.Rows(), len(f.Fields()))
+	}
+	for f.Redo() == nil {
+	}
+	initial := saveTestFile(t, f)
+
+	var states [][]byte
+	for _, change := range []func() error{
+		func() error { return f.Set(0, "NAME", "changed") },
+		func() error { return f.DelRow(1) },
+		func() error { _, err := f.NewRow(); return err },
+		func() error { return f.AddField("NOTE", Character, 5, 0) },
+		func() error { return f.Set(3, "NOTE", "note") },
+		func() error { return f.DelField("AMOUNT") },
+		func() error { return f.Pack() },
+	} {
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, saveTestFile(t, f))
+	}
+
+	for idx := len(states) - 1; idx >= 0; idx-- {
+		if err := f.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		want := initial
+		if idx > 0 {
+			want = states[idx-1]
+		}
+		if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(want)) {
+			t.Fatalf("unexpected state after undo of change %d", idx)
+		}
+	}
+
+	for idx := range states {
+		if err := f.Redo(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(states[idx])) {
+			t.Fatalf("unexpected state after redo of change %d", idx)
+		}
+	}
+	if err := f.Redo(); err == nil {
+		t.Fatal("expected nothing to redo")
+	}
+
+	// new edit drops undone edits
+	if err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Redo(); err == nil {
+		t.Fatal("expected nothing to redo")
+	}
+}
+
+// withoutDate clears date of last change in saved file
+func withoutDate(data []byte) []byte {
+	data = append([]byte(nil), data...)
+	copy(data[1:4], []byte{0, 0, 0})
+	return data
+}
+
+func TestHistoryCheckpoint(t *testing.T) {
+	f := newTestFile(t, 2, WithHistory(0))
+	if err := f.Checkpoint("start"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Checkpoint("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.RestoreCheckpoint("start"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 0, "NAME", "row0")
+	if err := f.RestoreCheckpoint("first"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 0, "NAME", "first")
+
+	// checkpoint after position of new edit is dropped
+	if err := f.RestoreCheckpoint("start"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(1, "NAME", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RestoreCheckpoint("first"); err == nil {
+		t.Fatal("expected error for dropped checkpoint")
+	}
+	if err := f.RestoreCheckpoint("unknown"); err == nil {
+		t.Fatal("expected error for unknown checkpoint")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	f := newTestFile(t, 2, WithHistory(0))
+	rlen := f.RLen()
+
+	g := newTestFile(t, 2, WithHistory(2*(2*rlen+editOverhead)))
+	for _, file := range []File{f, g} {
+		for idx := 0; idx < 5; idx++ {
+			if err := file.Set(0, "AMOUNT", string(rune('1'+idx))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// each edit of row keeps two images of row, so only two edits are kept
+	for idx := 0; idx < 2; idx++ {
+		if err := g.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Undo(); err == nil {
+		t.Fatal("expected the oldest edits to be dropped")
+	}
+	expectValue(t, g, 0, "AMOUNT", "3")
+
+	for f.Undo() == nil {
+	}
+	if f.Rows() != 0 {
+		t.Fatal("expected all edits to be kept without limit")
+	}
+}
+
+func TestHistoryLimitWithoutData(t *testing.T) {
+	f := newTestFile(t, 0, WithHistory(10*editOverhead))
+	for idx := 0; idx < 100; idx++ {
+		if _, err := f.NewRow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	undone := 0
+	for f.Undo() == nil {
+		undone++
+	}
+	if undone != 10 {
+		t.Fatalf("expected 10 edits to be kept, got %d", undone)
+	}
+	if f.Rows() != 90 {
+		t.Fatalf("expected 90 rows, got %d", f.Rows())
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	f := newTestFile(t, 1)
+	if err := f.Undo(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.Redo(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.Checkpoint("start"); err == nil {
+		t.Fatal("expected error")
+	}
+}
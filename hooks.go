@@ -0,0 +1,163 @@
+package dbf3
+
+import "sort"
+
+// hooks presents callbacks of file changes
+type hooks struct {
+	set    []func(row int, field, old, new string)
+	newRow []func(row int)
+	delRow []func(row int)
+	schema []func(fields []Field)
+
+	deferred bool     // callbacks are deferred until transaction is committed
+	pending  []func() // deferred calls
+}
+
+// call calls callback or defers it until transaction is committed
+func (h *hooks) call(fn func()) {
+	if h.deferred {
+		h.pending = append(h.pending, fn)
+		return
+	}
+	fn()
+}
+
+// deferCalls defers callbacks until transaction is finished
+func (h *hooks) deferCalls() {
+	h.deferred = true
+	h.pending = nil
+}
+
+// finish calls deferred callbacks if transaction is committed
+// or drops them if it's rolled back
+func (h *hooks) finish(commit bool) {
+	pending := h.pending
+	h.deferred, h.pending = false, nil
+	if !commit {
+		return
+	}
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// dirtyRows presents rows changed since the last reset
+type dirtyRows struct {
+	rows map[int]struct{}
+	all  bool // all rows changed (e.g. by schema change)
+}
+
+func (d *dirtyRows) mark(idx int) {
+	if d.all {
+		return
+	}
+	if d.rows == nil {
+		d.rows = make(map[int]struct{})
+	}
+	d.rows[idx] = struct{}{}
+}
+
+func (d *dirtyRows) markAll() {
+	d.all = true
+	d.rows = nil
+}
+
+// truncate forgets rows after specified count
+func (d *dirtyRows) truncate(rows int) {
+	for idx := range d.rows {
+		if idx >= rows {
+			delete(d.rows, idx)
+		}
+	}
+}
+
+// layoutChanged marks all rows as changed after change
+// of fields layout (even if row length is the same)
+func (f *file) layoutChanged() {
+	f.dirty.markAll()
+	if f.upd != nil {
+		f.upd.markAll()
+	}
+}
+
+func (f *file) OnSet(fn func(row int, field, old, new string)) {
+	f.hooks.set = append(f.hooks.set, fn)
+}
+
+func (f *file) OnNewRow(fn func(row int)) {
+	f.hooks.newRow = append(f.hooks.newRow, fn)
+}
+
+func (f *file) OnDelRow(fn func(row int)) {
+	f.hooks.delRow = append(f.hooks.delRow, fn)
+}
+
+func (f *file) OnSchemaChange(fn func(fields []Field)) {
+	f.hooks.schema = append(f.hooks.schema, fn)
+}
+
+func (f *file) DirtyRows() []int {
+	if f.dirty.all {
+		rows := make([]int, f.Rows())
+		for idx := range rows {
+			rows[idx] = idx
+		}
+		return rows
+	}
+
+	rows := make([]int, 0, len(f.dirty.rows))
+	for idx := range f.dirty.rows {
+		rows = append(rows, idx)
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+func (f *file) ResetDirty() {
+	f.dirty = dirtyRows{}
+}
+
+func (f *file) fireSet(row int, field, old, new string) {
+	if len(f.hooks.set) == 0 {
+		return
+	}
+	f.hooks.call(func() {
+		for _, fn := range f.hooks.set {
+			fn(row, field, old, new)
+		}
+	})
+}
+
+func (f *file) fireNewRow(row int) {
+	if len(f.hooks.newRow) == 0 {
+		return
+	}
+	f.hooks.call(func() {
+		for _, fn := range f.hooks.newRow {
+			fn(row)
+		}
+	})
+}
+
+func (f *file) fireDelRow(row int) {
+	if len(f.hooks.delRow) == 0 {
+		return
+	}
+	f.hooks.call(func() {
+		for _, fn := range f.hooks.delRow {
+			fn(row)
+		}
+	})
+}
+
+func (f *file) fireSchemaChange() {
+	if len(f.hooks.schema) == 0 {
+		return
+	}
+	fields := f.Fields()
+	f.hooks.call(func() {
+		for _, fn := range f.hooks.schema {
+			fn(fields)
+		}
+	})
+}
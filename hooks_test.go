@@ -0,0 +1,99 @@
+package dbf3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	f := newTestFile(t, 3)
+
+	type setCall struct {
+		row             int
+		field, old, new string
+	}
+	var sets []setCall
+	var newRows, delRows []int
+	var schemas [][]string
+	f.OnSet(func(row int, field, old, new string) {
+		sets = append(sets, setCall{row, field, old, new})
+	})
+	f.OnNewRow(func(row int) { newRows = append(newRows, row) })
+	f.OnDelRow(func(row int) { delRows = append(delRows, row) })
+	f.OnSchemaChange(func(fields []Field) {
+		var names []string
+		for _, fld := range fields {
+			names = append(names, fld.Name())
+		}
+		schemas = append(schemas, names)
+	})
+
+	if err := f.Set(1, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := f.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DelRow(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddField("NOTE", Character, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DelField("NOTE"); err != nil {
+		t.Fatal(err)
+	}
+	// failed change doesn't call callbacks
+	if err := f.Set(1, "NAME", "too long value"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if want := []setCall{{1, "NAME", "row1", "changed"}}; !reflect.DeepEqual(sets, want) {
+		t.Fatalf("unexpected OnSet calls %v", sets)
+	}
+	if !reflect.DeepEqual(newRows, []int{idx}) {
+		t.Fatalf("unexpected OnNewRow calls %v", newRows)
+	}
+	if !reflect.DeepEqual(delRows, []int{0}) {
+		t.Fatalf("unexpected OnDelRow calls %v", delRows)
+	}
+	want := [][]string{{"NAME", "AMOUNT", "NOTE"}, {"NAME", "AMOUNT"}}
+	if !reflect.DeepEqual(schemas, want) {
+		t.Fatalf("unexpected OnSchemaChange calls %v", schemas)
+	}
+}
+
+func TestDirtyRows(t *testing.T) {
+	f := newTestFile(t, 5)
+	f.ResetDirty()
+	if rows := f.DirtyRows(); len(rows) != 0 {
+		t.Fatalf("expected no dirty rows, got %v", rows)
+	}
+
+	if err := f.Set(3, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DelRow(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewRow(); err != nil {
+		t.Fatal(err)
+	}
+	if rows := f.DirtyRows(); !reflect.DeepEqual(rows, []int{1, 3, 5}) {
+		t.Fatalf("unexpected dirty rows %v", rows)
+	}
+
+	// schema change changes all rows
+	if err := f.AddField("NOTE", Character, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if rows := f.DirtyRows(); !reflect.DeepEqual(rows, []int{0, 1, 2, 3, 4, 5}) {
+		t.Fatalf("unexpected dirty rows %v", rows)
+	}
+
+	f.ResetDirty()
+	if rows := f.DirtyRows(); len(rows) != 0 {
+		t.Fatalf("expected no dirty rows, got %v", rows)
+	}
+}
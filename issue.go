@@ -0,0 +1,32 @@
+package dbf3
+
+import "strconv"
+
+// Issue presents problem found in DBF file
+type Issue struct {
+	Row   int    // row index (-1 if issue is not related to row)
+	Field int    // field index (-1 if issue is not related to field)
+	Msg   string // description of the problem
+}
+
+func (i Issue) String() string {
+	s := i.Msg
+	if i.Field >= 0 {
+		s = "field " + strconv.Itoa(i.Field) + ": " + s
+	}
+	if i.Row >= 0 {
+		s = "row " + strconv.Itoa(i.Row) + ": " + s
+	}
+	return s
+}
+
+// issues presents list of issues
+type issues []Issue
+
+func (is *issues) add(row, field int, msg string) {
+	*is = append(*is, Issue{Row: row, Field: field, Msg: msg})
+}
+
+func (is *issues) addHeader(msg string) {
+	is.add(-1, -1, msg)
+}
@@ -0,0 +1,390 @@
+package dbf3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalMagic starts journal file
+var journalMagic = []byte("DBF3WAL1")
+
+// journals presents journals held by this process, since locks
+// of the same process don't conflict (and closing of any descriptor
+// of journal releases its lock)
+var journals = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// holdJournal marks journal as held (or released) by this process.
+// Returns false if journal is already held
+func holdJournal(name string, hold bool) bool {
+	name = absName(name)
+	journals.Lock()
+	defer journals.Unlock()
+	if hold && journals.names[name] {
+		return false
+	}
+	if hold {
+		journals.names[name] = true
+	} else {
+		delete(journals.names, name)
+	}
+	return true
+}
+
+// journalHeld returns true if journal is held by this process
+func journalHeld(name string) bool {
+	name = absName(name)
+	journals.Lock()
+	defer journals.Unlock()
+	return journals.names[name]
+}
+
+func absName(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
+
+// journalName returns name of journal file of specified table
+func journalName(fileName string) string {
+	return fileName + ".wal"
+}
+
+// journal presents write-ahead journal of file updated in place.
+// Before-images of overwritten data are written into journal
+// (and synced) before the data is overwritten. Journal is removed
+// by checkpoint, after changes are synced. Journal file is locked
+// while it exists, and if unlocked journal exists on open,
+// file is rolled back to the state of the last checkpoint
+type journal struct {
+	rw   *os.File // table file
+	name string   // journal file name
+
+	fd    *os.File // journal file (nil if there are no changes since checkpoint)
+	size  int64    // table size at the last checkpoint
+	saved map[[2]int64]bool
+}
+
+func newJournal(rw *os.File, fileName string) *journal {
+	return &journal{rw: rw, name: journalName(fileName)}
+}
+
+func (j *journal) ReadAt(p []byte, off int64) (int, error) {
+	return j.rw.ReadAt(p, off)
+}
+
+func (j *journal) WriteAt(p []byte, off int64) (int, error) {
+	if err := j.save(off, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return j.rw.WriteAt(p, off)
+}
+
+func (j *journal) Truncate(size int64) error {
+	if err := j.start(); err != nil {
+		return err
+	}
+	if err := j.save(size, j.size-size); err != nil {
+		return err
+	}
+	return j.rw.Truncate(size)
+}
+
+// start creates journal file (if it's not created yet)
+func (j *journal) start() error {
+	if j.fd != nil {
+		return nil
+	}
+
+	st, err := j.rw.Stat()
+	if err != nil {
+		return err
+	}
+	if !holdJournal(j.name, true) {
+		return errors.New("journal is used by another file")
+	}
+	fd, err := lockJournal(j.name, true, false)
+	if err != nil || fd == nil {
+		holdJournal(j.name, false)
+		if err == nil {
+			err = errJournalUsed
+		}
+		return err
+	}
+
+	j.fd = fd
+	if err := fd.Truncate(0); err != nil {
+		j.close()
+		return err
+	}
+
+	buf := make([]byte, len(journalMagic)+8)
+	copy(buf, journalMagic)
+	binary.LittleEndian.PutUint64(buf[len(journalMagic):], uint64(st.Size()))
+	if _, err := fd.Write(buf); err != nil {
+		j.close()
+		return err
+	}
+	if err := fd.Sync(); err != nil {
+		j.close()
+		return err
+	}
+
+	j.size = st.Size()
+	j.saved = make(map[[2]int64]bool)
+	return nil
+}
+
+// save writes before-image of specified range into journal.
+// Data after the end of file at the last checkpoint is not saved,
+// since file is truncated on rollback
+func (j *journal) save(off, length int64) error {
+	if err := j.start(); err != nil {
+		return err
+	}
+	if off+length > j.size {
+		length = j.size - off
+	}
+	if length <= 0 || j.saved[[2]int64{off, length}] {
+		return nil
+	}
+
+	buf := make([]byte, 12+length+4)
+	binary.LittleEndian.PutUint64(buf, uint64(off))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(length))
+	if _, err := j.rw.ReadAt(buf[12:12+length], off); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(buf[12+length:], crc32.ChecksumIEEE(buf[:12+length]))
+
+	if _, err := j.fd.Write(buf); err != nil {
+		return err
+	}
+	if err := j.fd.Sync(); err != nil {
+		return err
+	}
+	j.saved[[2]int64{off, length}] = true
+	return nil
+}
+
+// checkpoint syncs file and removes journal
+func (j *journal) checkpoint() error {
+	if j.fd == nil {
+		return nil
+	}
+
+	if err := j.rw.Sync(); err != nil {
+		return err
+	}
+	if !lockSupported {
+		// journal is closed before it's removed (see removeJournal),
+		// so it's emptied first: empty journal doesn't roll back anything
+		if err := j.fd.Truncate(0); err != nil {
+			return err
+		}
+		if err := j.fd.Sync(); err != nil {
+			return err
+		}
+	}
+
+	fd := j.fd
+	j.fd, j.saved = nil, nil
+	defer holdJournal(j.name, false)
+	return removeJournal(fd, j.name)
+}
+
+// removeJournal removes and closes journal file. Locked journal
+// is removed before it's closed (and unlocked), so it's not taken
+// by other processes. Otherwise it's closed first, since opened
+// file cannot be removed on some platforms (Windows)
+func removeJournal(fd *os.File, name string) error {
+	if !lockSupported {
+		if err := fd.Close(); err != nil {
+			return err
+		}
+		return os.Remove(name)
+	}
+
+	err := os.Remove(name)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// close closes (and unlocks) journal file without removing it,
+// so unflushed changes are rolled back on next open
+func (j *journal) close() error {
+	if j.fd == nil {
+		return nil
+	}
+	err := j.fd.Close()
+	holdJournal(j.name, false)
+	j.fd, j.saved = nil, nil
+	return err
+}
+
+var (
+	errJournalUsed = errors.New("journal is used by another process " +
+		"or interrupted update must be rolled back by RollbackJournal")
+	errJournalUnlocked = errors.New("file has journal, which cannot be locked " +
+		"on this platform: interrupted update must be rolled back by RollbackJournal")
+)
+
+// lockJournal opens journal file and locks it. Returns nil file
+// if journal is locked by another process (or it doesn't exist
+// and create is false). On platforms without locking journal
+// is not locked: it's created only if it doesn't exist, and
+// existing journal is returned only if force is true
+// (otherwise errJournalUnlocked is returned)
+func lockJournal(name string, create, force bool) (*os.File, error) {
+	if !create && journalHeld(name) {
+		return nil, nil
+	}
+
+	flag := os.O_RDWR
+	if create {
+		flag |= os.O_CREATE
+		if !lockSupported {
+			flag |= os.O_EXCL
+		}
+	}
+
+	for {
+		fd, err := os.OpenFile(name, flag, 0666)
+		if !create && errors.Is(err, os.ErrNotExist) ||
+			create && errors.Is(err, os.ErrExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !lockSupported {
+			if !create && !force {
+				fd.Close()
+				return nil, errJournalUnlocked
+			}
+			return fd, nil
+		}
+
+		busy, err := lockRange(fd, 0, 0, true)
+		if err != nil || busy {
+			fd.Close()
+			return nil, err
+		}
+
+		// journal could be removed before it was locked
+		// (by its process on checkpoint or by recovery)
+		fst, err := fd.Stat()
+		if err != nil {
+			fd.Close()
+			return nil, err
+		}
+		st, err := os.Stat(name)
+		if err == nil && os.SameFile(st, fst) {
+			return fd, nil
+		}
+		fd.Close()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// checkJournal returns error if file has interrupted update.
+// It's used by read only opens, which must not change the file
+// (journal of update in progress is ignored)
+func checkJournal(fileName string) error {
+	fd, err := lockJournal(journalName(fileName), false, false)
+	if err != nil || fd == nil {
+		return err
+	}
+	fd.Close()
+	return errors.New("file has interrupted update, it must be opened for update to roll it back")
+}
+
+// RollbackJournal rolls back interrupted update of file, which
+// was opened with WithJournal option (it's done if journal of file
+// exists and it's not used by another process). Interrupted update
+// is rolled back on open for update, so it's needed only on platforms
+// without file locking (e.g. Windows), where it must be called only
+// when the file is not updated by other processes
+func RollbackJournal(fileName string) error {
+	return recoverJournal(fileName, true)
+}
+
+// recoverJournal rolls back interrupted update of file
+// (if journal of file exists and it's not used by another process)
+func recoverJournal(fileName string, force bool) error {
+	fd, err := lockJournal(journalName(fileName), false, force)
+	if err != nil || fd == nil {
+		return err
+	}
+
+	data, err := io.ReadAll(fd)
+	if err == nil {
+		err = rollback(fileName, data)
+	}
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	return removeJournal(fd, journalName(fileName))
+}
+
+// rollback writes before-images of journal data into file
+func rollback(fileName string, data []byte) error {
+	head := len(journalMagic) + 8
+	if len(data) < head || !bytes.Equal(data[:len(journalMagic)], journalMagic) {
+		// journal was not written completely, so file was not changed
+		return nil
+	}
+	size := int64(binary.LittleEndian.Uint64(data[len(journalMagic):]))
+
+	// complete records only: the last record could be interrupted,
+	// but then its data was not overwritten
+	type record struct {
+		off  int64
+		data []byte
+	}
+	var records []record
+	for pos := head; pos+12 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[pos+8:]))
+		end := pos + 12 + length
+		if length < 0 || end+4 > len(data) ||
+			crc32.ChecksumIEEE(data[pos:end]) != binary.LittleEndian.Uint32(data[end:]) {
+			break
+		}
+		records = append(records, record{
+			off:  int64(binary.LittleEndian.Uint64(data[pos:])),
+			data: data[pos+12 : end],
+		})
+		pos = end + 4
+	}
+
+	table, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	// the earliest before-image of the range is written last
+	for idx := len(records) - 1; idx >= 0; idx-- {
+		if _, err := table.WriteAt(records[idx].data, records[idx].off); err != nil {
+			return err
+		}
+	}
+	if err := table.Truncate(size); err != nil {
+		return err
+	}
+	return table.Sync()
+}
@@ -0,0 +1,239 @@
+package dbf3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crash closes file without flush, as if the process was killed
+func crash(t *testing.T, f File) {
+	t.Helper()
+	ff := f.(*file)
+	if err := ff.journal.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ff.closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func journalExists(fileName string) bool {
+	_, err := os.Stat(journalName(fileName))
+	return err == nil
+}
+
+func TestJournalCheckpoint(t *testing.T) {
+	for _, open := range []struct {
+		name string
+		fn   func(string, ...Option) (File, error)
+	}{
+		{"OpenFileAt", OpenFileAt},
+		{"OpenUpdate", OpenUpdate},
+	} {
+		t.Run(open.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "test.dbf")
+			if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := open.fn(fileName, WithJournal())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Set(0, "NAME", "changed"); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if journalExists(fileName) {
+				t.Fatal("expected journal to be removed by Flush")
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			g, err := OpenFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectValue(t, g, 0, "NAME", "changed")
+		})
+	}
+}
+
+func TestJournalRecovery(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	before := mustReadFile(t, fileName)
+
+	f, err := OpenFileAt(fileName, WithJournal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewRow(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(3, "NAME", "new"); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, f)
+	if !journalExists(fileName) {
+		t.Fatal("expected journal of interrupted update")
+	}
+
+	// read only opens don't change the file
+	if _, err := OpenFile(fileName); err == nil {
+		t.Fatal("expected error for interrupted update")
+	}
+	if !journalExists(fileName) {
+		t.Fatal("journal is removed by read only open")
+	}
+
+	if !lockSupported {
+		// journal cannot be locked, so it's not rolled back on open
+		if _, err := OpenUpdate(fileName); err == nil {
+			t.Fatal("expected error for journal, which cannot be locked")
+		}
+		if err := RollbackJournal(fileName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if journalExists(fileName) {
+		t.Fatal("expected journal to be removed by recovery")
+	}
+	if !bytes.Equal(mustReadFile(t, fileName), before) {
+		t.Fatal("file is not rolled back")
+	}
+}
+
+func TestJournalIncomplete(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	before := mustReadFile(t, fileName)
+
+	// header of journal was not written completely
+	if err := os.WriteFile(journalName(fileName), journalMagic[:4], 0666); err != nil {
+		t.Fatal(err)
+	}
+	if !lockSupported {
+		if err := RollbackJournal(fileName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := OpenFileAt(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if journalExists(fileName) {
+		t.Fatal("expected journal to be removed")
+	}
+	if !bytes.Equal(mustReadFile(t, fileName), before) {
+		t.Fatal("file is changed")
+	}
+}
+
+func TestJournalInUse(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(fileName, WithJournal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// journal of update in progress is not rolled back
+	r, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, r, 0, "NAME", "changed")
+	g, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !journalExists(fileName) {
+		t.Fatal("journal of update in progress is removed")
+	}
+
+	// another file cannot write the same journal
+	h, err := OpenFileAt(fileName, WithJournal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Set(1, "NAME", "other"); err == nil {
+		t.Fatal("expected error for journal in use")
+	}
+
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if journalExists(fileName) {
+		t.Fatal("expected journal to be removed by Flush")
+	}
+}
+
+func TestRollbackJournal(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	before := mustReadFile(t, fileName)
+
+	f, err := OpenFileAt(fileName, WithJournal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// journal of update in progress is kept
+	if err := RollbackJournal(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if !journalExists(fileName) {
+		t.Fatal("journal of update in progress is removed")
+	}
+
+	crash(t, f)
+	if err := RollbackJournal(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if journalExists(fileName) {
+		t.Fatal("expected journal to be removed")
+	}
+	if !bytes.Equal(mustReadFile(t, fileName), before) {
+		t.Fatal("file is not rolled back")
+	}
+	if err := RollbackJournal(fileName); err != nil {
+		t.Fatal(err)
+	}
+}
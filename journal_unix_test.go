@@ -0,0 +1,79 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dbf3
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJournalProbe is run as separate process by openInProcess
+func TestJournalProbe(t *testing.T) {
+	fileName := os.Getenv("DBF3_JOURNAL_PROBE")
+	if fileName == "" {
+		t.Skip("run by other tests")
+	}
+
+	f, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	name, err := f.Get(0, "NAME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("<" + name + ">")
+}
+
+// openInProcess opens file for update in another process
+// and returns value of NAME in the first row
+func openInProcess(t *testing.T, fileName string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestJournalProbe$")
+	cmd.Env = append(os.Environ(), "DBF3_JOURNAL_PROBE="+fileName)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("probe failed: %v\n%s", err, out)
+	}
+	start, end := strings.Index(string(out), "<"), strings.Index(string(out), ">")
+	if start < 0 || end < start {
+		t.Fatalf("unexpected probe output %s", out)
+	}
+	return string(out[start+1 : end])
+}
+
+func TestJournalProcesses(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(fileName, WithJournal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// journal is locked by this process
+	if name := openInProcess(t, fileName); name != "changed" {
+		t.Fatalf("update in progress is rolled back by another process: %q", name)
+	}
+	if !journalExists(fileName) {
+		t.Fatal("journal of update in progress is removed")
+	}
+
+	crash(t, f)
+	if name := openInProcess(t, fileName); name != "row0" {
+		t.Fatalf("interrupted update is not rolled back: %q", name)
+	}
+	if journalExists(fileName) {
+		t.Fatal("expected journal to be removed by recovery")
+	}
+}
@@ -0,0 +1,268 @@
+package dbf3
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+// LockScheme presents scheme of record and file locks,
+// compatible with other xBase products
+type LockScheme byte
+
+// Lock schemes
+const (
+	// LockFoxPro presents FoxPro (and Visual FoxPro) locking scheme
+	LockFoxPro LockScheme = iota
+	// LockClipper presents Clipper locking scheme
+	LockClipper
+)
+
+const (
+	foxLockBase     = 0x7FFFFFFE // records are locked downwards from base
+	foxLockSize     = 0x07FFFFFF
+	clipperLockBase = 1000000000 // records are locked upwards from base
+	clipperLockSize = 1000000000
+
+	lockRetry = 10 * time.Millisecond
+)
+
+// recordLock returns offset of lock of record with specified
+// number (1-based, 0 is used for header lock on append)
+func (s LockScheme) recordLock(recno int) int64 {
+	if s == LockClipper {
+		return clipperLockBase + int64(recno)
+	}
+	return foxLockBase - int64(recno)
+}
+
+// fileLock returns offset and length of file lock
+// (it covers locks of all records)
+func (s LockScheme) fileLock() (int64, int64) {
+	if s == LockClipper {
+		return clipperLockBase, clipperLockSize
+	}
+	return foxLockBase - foxLockSize + 1, foxLockSize
+}
+
+// locker holds locks of disk-backed file. Locks are advisory
+// byte range locks placed far beyond data, as it's done by
+// other xBase products. Since fcntl locks of a process don't nest
+// (releasing a range releases all locks inside it), file lock is
+// released around held record locks and record locks are not
+// released while file is locked
+type locker struct {
+	fd      *os.File
+	scheme  LockScheme
+	timeout time.Duration
+
+	file    bool         // file is locked
+	records map[int]bool // locked records (by index)
+}
+
+// newLocker creates locker of file (nil if locking is not supported)
+func newLocker(fd *os.File, o *options) *locker {
+	if !lockSupported {
+		return nil
+	}
+	return &locker{
+		fd:      fd,
+		scheme:  o.lockScheme,
+		timeout: o.lockTimeout,
+		records: make(map[int]bool),
+	}
+}
+
+// held checks if record with specified index (-1 for header)
+// is locked already
+func (l *locker) held(idx int) bool {
+	return l.file || l.records[idx]
+}
+
+// lock places lock, retrying until timeout expires
+func (l *locker) lock(offset, length int64) error {
+	deadline := time.Now().Add(l.timeout)
+	for {
+		busy, err := lockRange(l.fd, offset, length, true)
+		if err != nil {
+			return err
+		}
+		if !busy {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return errors.New("locked by another process")
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+func (l *locker) lockRecord(idx int) error {
+	if l.held(idx) {
+		return nil
+	}
+	if err := l.lock(l.scheme.recordLock(idx+1), 1); err != nil {
+		return err
+	}
+	l.records[idx] = true
+	return nil
+}
+
+func (l *locker) unlockRecord(idx int) error {
+	if !l.records[idx] {
+		return nil
+	}
+	delete(l.records, idx)
+	if l.file {
+		// record is covered by file lock
+		return nil
+	}
+	_, err := lockRange(l.fd, l.scheme.recordLock(idx+1), 1, false)
+	return err
+}
+
+func (l *locker) lockFile() error {
+	if l.file {
+		return nil
+	}
+	offset, length := l.scheme.fileLock()
+	if err := l.lock(offset, length); err != nil {
+		return err
+	}
+	l.file = true
+	return nil
+}
+
+// unlock releases all locks
+func (l *locker) unlock() error {
+	var err error
+	idxs := make([]int, 0, len(l.records))
+	for idx := range l.records {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	for _, idx := range idxs {
+		if uerr := l.unlockRecord(idx); err == nil {
+			err = uerr
+		}
+	}
+
+	if uerr := l.unlockFile(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// unlockFile releases file lock, keeping held record locks
+func (l *locker) unlockFile() error {
+	if !l.file {
+		return nil
+	}
+	l.file = false
+
+	offset, length := l.scheme.fileLock()
+	held := make([]int64, 0, len(l.records))
+	for idx := range l.records {
+		held = append(held, l.scheme.recordLock(idx+1))
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i] < held[j] })
+
+	// release ranges between held records
+	for _, rec := range held {
+		if rec < offset || rec >= offset+length {
+			continue
+		}
+		if rec > offset {
+			if _, err := lockRange(l.fd, offset, rec-offset, false); err != nil {
+				return err
+			}
+		}
+		length -= rec + 1 - offset
+		offset = rec + 1
+	}
+	if length == 0 {
+		return nil
+	}
+	_, err := lockRange(l.fd, offset, length, false)
+	return err
+}
+
+func (f *file) LockFile() error {
+	if f.lock == nil {
+		return errors.New("locking is not supported for this file")
+	}
+	if err := f.lock.lockFile(); err != nil {
+		return err
+	}
+
+	// other processes could change the file before it was locked
+	f.disk.dropAll()
+	return f.disk.reload()
+}
+
+func (f *file) LockRecord(idx int) error {
+	if f.lock == nil {
+		return errors.New("locking is not supported for this file")
+	}
+	if idx < 0 || idx >= f.Rows() {
+		return errors.New("out of range")
+	}
+	if f.lock.held(idx) {
+		return nil
+	}
+	if err := f.lock.lockRecord(idx); err != nil {
+		return err
+	}
+
+	// other processes could change the row before it was locked
+	if err := f.disk.refreshRow(idx); err != nil {
+		f.lock.unlockRecord(idx)
+		return err
+	}
+	return nil
+}
+
+func (f *file) Unlock() error {
+	if f.lock == nil {
+		return errors.New("locking is not supported for this file")
+	}
+	return f.lock.unlock()
+}
+
+// lockRow locks row with specified index (-1 for header) for the time
+// of change, if it's not locked already. Returned function releases lock
+func (f *file) lockRow(idx int) (func(), error) {
+	if f.lock == nil || f.lock.held(idx) {
+		return func() {}, nil
+	}
+	if err := f.lock.lockRecord(idx); err != nil {
+		return nil, err
+	}
+
+	// other processes could append rows or change the row
+	// before it was locked (other cached rows are kept)
+	var err error
+	if idx < 0 {
+		err = f.disk.reload()
+	} else {
+		err = f.disk.refreshRow(idx)
+	}
+	if err != nil {
+		f.lock.unlockRecord(idx)
+		return nil, err
+	}
+	return func() { f.lock.unlockRecord(idx) }, nil
+}
+
+// lockTable locks whole file for the time of change,
+// if it's not locked already. Returned function releases lock
+func (f *file) lockTable() (func(), error) {
+	if f.lock == nil || f.lock.file {
+		return func() {}, nil
+	}
+	if err := f.LockFile(); err != nil {
+		return nil, err
+	}
+	return func() { f.lock.unlockFile() }, nil
+}
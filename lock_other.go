@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package dbf3
+
+import (
+	"errors"
+	"os"
+)
+
+const lockSupported = false
+
+// lockRange places (or releases) exclusive byte range lock.
+// Not supported on this platform
+func lockRange(fd *os.File, offset, length int64, lock bool) (bool, error) {
+	return false, errors.New("locking not supported on this platform")
+}
@@ -0,0 +1,45 @@
+package dbf3
+
+import "testing"
+
+func TestLockSchemeOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		scheme       LockScheme
+		header, rec1 int64
+		fileOffset   int64
+		fileLength   int64
+	}{
+		{LockFoxPro, 0x7FFFFFFE, 0x7FFFFFFD, 0x7FFFFFFE - 0x07FFFFFF + 1, 0x07FFFFFF},
+		{LockClipper, 1000000000, 1000000001, 1000000000, 1000000000},
+	} {
+		if off := tc.scheme.recordLock(0); off != tc.header {
+			t.Errorf("scheme %d: expected header lock at %d, got %d", tc.scheme, tc.header, off)
+		}
+		if off := tc.scheme.recordLock(1); off != tc.rec1 {
+			t.Errorf("scheme %d: expected record lock at %d, got %d", tc.scheme, tc.rec1, off)
+		}
+		off, length := tc.scheme.fileLock()
+		if off != tc.fileOffset || length != tc.fileLength {
+			t.Errorf("scheme %d: unexpected file lock %d, %d", tc.scheme, off, length)
+		}
+		// file lock covers locks of header and records
+		for _, rec := range []int64{tc.scheme.recordLock(0), tc.scheme.recordLock(1000000)} {
+			if rec < off || rec >= off+length {
+				t.Errorf("scheme %d: lock %d is not covered by file lock", tc.scheme, rec)
+			}
+		}
+	}
+}
+
+func TestLockInMemory(t *testing.T) {
+	f := newTestFile(t, 1)
+	if err := f.LockFile(); err == nil {
+		t.Fatal("expected error for in-memory file")
+	}
+	if err := f.LockRecord(0); err == nil {
+		t.Fatal("expected error for in-memory file")
+	}
+	if err := f.Unlock(); err == nil {
+		t.Fatal("expected error for in-memory file")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dbf3
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+const lockSupported = true
+
+// lockRange places (or releases) exclusive byte range lock
+// with fcntl. Returns true if range is locked by another process
+func lockRange(fd *os.File, offset, length int64, lock bool) (bool, error) {
+	lk := syscall.Flock_t{
+		Type:   syscall.F_WRLCK,
+		Whence: io.SeekStart,
+		Start:  offset,
+		Len:    length,
+	}
+	if !lock {
+		lk.Type = syscall.F_UNLCK
+	}
+
+	err := syscall.FcntlFlock(fd.Fd(), syscall.F_SETLK, &lk)
+	if err == syscall.EAGAIN || err == syscall.EACCES {
+		return true, nil
+	}
+	return false, err
+}
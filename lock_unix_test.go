@@ -0,0 +1,180 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dbf3
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLockProbe is run as separate process by probeLock,
+// since locks of the same process don't conflict
+func TestLockProbe(t *testing.T) {
+	args := strings.Split(os.Getenv("DBF3_LOCK_PROBE"), ":")
+	if len(args) != 2 {
+		t.Skip("run by other tests")
+	}
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := os.OpenFile(args[0], os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	busy, err := lockRange(fd, offset, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if busy {
+		os.Stdout.WriteString("<busy>")
+	} else {
+		os.Stdout.WriteString("<free>")
+	}
+}
+
+// probeLock checks if byte at offset is locked by another process
+func probeLock(t *testing.T, fileName string, offset int64) bool {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockProbe$")
+	cmd.Env = append(os.Environ(), "DBF3_LOCK_PROBE="+fileName+":"+strconv.FormatInt(offset, 10))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("probe failed: %v\n%s", err, out)
+	}
+	switch {
+	case strings.Contains(string(out), "<busy>"):
+		return true
+	case strings.Contains(string(out), "<free>"):
+		return false
+	}
+	t.Fatalf("unexpected probe output %s", out)
+	return false
+}
+
+func TestLockProcesses(t *testing.T) {
+	for _, scheme := range []LockScheme{LockFoxPro, LockClipper} {
+		fileName := filepath.Join(t.TempDir(), "test.dbf")
+		if err := newTestFile(t, 5).SaveFile(fileName); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := OpenFileAt(fileName, WithLockScheme(scheme), WithLockTimeout(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectLocks := func(step string, locked map[int]bool) {
+			t.Helper()
+			for idx := 0; idx < 3; idx++ {
+				if busy := probeLock(t, fileName, scheme.recordLock(idx+1)); busy != locked[idx] {
+					t.Fatalf("scheme %d, %s: row %d locked %v, expected %v",
+						scheme, step, idx, busy, locked[idx])
+				}
+			}
+		}
+
+		if err := f.LockRecord(1); err != nil {
+			t.Fatal(err)
+		}
+		expectLocks("record lock", map[int]bool{1: true})
+
+		// changes of other rows lock them only for the time of change
+		if err := f.Set(2, "NAME", "changed"); err != nil {
+			t.Fatal(err)
+		}
+		expectLocks("change", map[int]bool{1: true})
+
+		// file lock of Pack must not release record lock
+		if err := f.Pack(); err != nil {
+			t.Fatal(err)
+		}
+		expectLocks("pack", map[int]bool{1: true})
+
+		if err := f.LockFile(); err != nil {
+			t.Fatal(err)
+		}
+		expectLocks("file lock", map[int]bool{0: true, 1: true, 2: true})
+
+		if err := f.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		expectLocks("unlock", nil)
+
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLockAppend(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 2).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenAppend(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := LockFoxPro.recordLock(0)
+	if !probeLock(t, fileName, header) {
+		t.Fatal("expected header lock while appending")
+	}
+	if err := w.WriteRow([]string{"new", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if probeLock(t, fileName, header) {
+		t.Fatal("expected header lock to be released by Close")
+	}
+}
+
+func TestLockCache(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	expectValue(t, f, 1, "NAME", "row1") // page is cached
+
+	// another program changes rows 1 and 2
+	other, err := OpenFileAt(fileName, WithPageCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Set(1, "AMOUNT", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Set(2, "NAME", "other"); err != nil {
+		t.Fatal(err)
+	}
+	// other.Close would release locks of this process too
+	defer other.Close()
+
+	// changed row is re-read after locking
+	if err := f.Set(1, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 1, "AMOUNT", "7")
+	// other cached rows are kept until they are locked
+	expectValue(t, f, 2, "NAME", "row2")
+	if err := f.LockRecord(2); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 2, "NAME", "other")
+}
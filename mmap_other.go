@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package dbf3
+
+import "errors"
+
+// OpenMmap opens DBF from file in read only mode using memory mapping.
+// Not supported on this platform
+func OpenMmap(fileName string, opts ...Option) (File, error) {
+	return nil, errors.New("memory mapping not supported on this platform")
+}
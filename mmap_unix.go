@@ -0,0 +1,85 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dbf3
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// OpenMmap opens DBF from file in read only mode using memory mapping.
+// Values are read directly from the mapping,
+// which must be released by Close method
+func OpenMmap(fileName string, opts ...Option) (File, error) {
+	if err := checkJournal(fileName); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	st, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() < 33 || st.Size() != int64(int(st.Size())) {
+		return nil, errors.New("invalid file size")
+	}
+
+	mapping, err := syscall.Mmap(
+		int(fd.Fd()), 0, int(st.Size()), syscall.PROT_READ, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openMapping(mapping, opts...)
+	if err != nil {
+		syscall.Munmap(mapping)
+		return nil, err
+	}
+	return f, nil
+}
+
+func openMapping(mapping []byte, opts ...Option) (File, error) {
+	hdr, fields, fieldsIdx, err := readHead(bytes.NewReader(mapping))
+	if err != nil {
+		return nil, err
+	}
+
+	end := int(hdr.hlen) + int(hdr.rlen)*int(hdr.rows) + 1
+	if len(mapping) < end {
+		return nil, errors.New("unexpected end of file")
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.applyLang(&hdr)
+
+	f := o.newFile(hdr, fields, fieldsIdx, mapping[hdr.hlen:end])
+	f.readOnly = true
+	f.closer = &mmapping{f, mapping}
+	return o.wrap(f), nil
+}
+
+// mmapping presents memory mapping of file
+type mmapping struct {
+	f    *file
+	data []byte
+}
+
+func (m *mmapping) Close() error {
+	// make file empty to avoid access to unmapped memory
+	m.f.data = []byte{eof}
+	m.f.header.rows = 0
+	return syscall.Munmap(m.data)
+}
@@ -0,0 +1,55 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dbf3
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestOpenMmap(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 50).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenMmap(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 50 {
+		t.Fatalf("expected 50 rows, got %d", f.Rows())
+	}
+	for _, idx := range []int{0, 25, 49} {
+		expectValue(t, f, idx, "NAME", "row"+strconv.Itoa(idx))
+	}
+
+	if err := f.Set(0, "NAME", "changed"); err == nil {
+		t.Fatal("expected error for changing read only file")
+	}
+	if _, err := f.NewRow(); err == nil {
+		t.Fatal("expected error for adding row into read only file")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get(0, "NAME"); err == nil {
+		t.Fatal("expected error after unmapping")
+	}
+}
+
+func TestOpenMmapTruncated(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	data := saveTestFile(t, newTestFile(t, 2))
+	if err := os.WriteFile(fileName, data[:len(data)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenMmap(fileName); err == nil {
+		t.Fatal("expected error for truncated file")
+	}
+}
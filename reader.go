@@ -0,0 +1,140 @@
+package dbf3
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"time"
+)
+
+type reader struct {
+	header    header
+	fields    []*field
+	fieldsIdx map[string]int
+	converter TextConverter
+
+	r   io.Reader
+	idx int // index of next row
+}
+
+// NewReader creates DBF stream reader.
+// Header and fields are read immediately
+func NewReader(rd io.Reader, opts ...Option) (Reader, error) {
+	r := bufio.NewReader(rd)
+
+	hdr, fields, fieldsIdx, err := readHead(r)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.applyLang(&hdr)
+
+	return &reader{
+		header:    hdr,
+		fields:    fields,
+		fieldsIdx: fieldsIdx,
+		converter: o.convCtor(LangID(hdr.lang)),
+		r:         r,
+	}, nil
+}
+
+func (r *reader) Rows() int          { return int(r.header.rows) }
+func (r *reader) HLen() int          { return int(r.header.hlen) }
+func (r *reader) RLen() int          { return int(r.header.rlen) }
+func (r *reader) Lang() LangID       { return LangID(r.header.lang) }
+func (r *reader) Changed() time.Time { return r.header.changedTime() }
+
+func (r *reader) Fields() []Field {
+	fields := make([]Field, len(r.fields))
+
+	for idx := range r.fields {
+		fields[idx] = r.fields[idx]
+	}
+
+	return fields
+}
+
+func (r *reader) HasField(field string) bool {
+	_, ok := r.fieldsIdx[field]
+	return ok
+}
+
+func (r *reader) Read() (Row, error) {
+	if r.idx >= r.Rows() {
+		return nil, io.EOF
+	}
+
+	data := make([]byte, r.RLen())
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if data[0] == eof {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	r.idx++
+	return &streamRow{r, r.idx - 1, data}, nil
+}
+
+// streamRow presents row read by stream reader
+type streamRow struct {
+	r    *reader
+	idx  int
+	data []byte
+}
+
+func (sr *streamRow) Index() int {
+	return sr.idx
+}
+
+func (sr *streamRow) Deleted() bool {
+	return sr.data[0] == deleted
+}
+
+func (sr *streamRow) Del() error {
+	return errors.New("read only row")
+}
+
+func (sr *streamRow) Get(fld string) (string, error) {
+	fldIdx, ok := sr.r.fieldsIdx[fld]
+	if !ok {
+		return "", errors.New("field not found")
+	}
+
+	return sr.r.converter.Decode(sr.r.fields[fldIdx].value(sr.data))
+}
+
+func (sr *streamRow) Value(fld string) (interface{}, error) {
+	val, err := sr.Get(fld)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseValue(sr.r.fields[sr.r.fieldsIdx[fld]].Type(), val)
+}
+
+func (sr *streamRow) rawChar(fld string) (string, bool, error) {
+	fldIdx, ok := sr.r.fieldsIdx[fld]
+	if !ok {
+		return "", false, errors.New("field not found")
+	}
+	field := sr.r.fields[fldIdx]
+	if field.Type() != Character {
+		return "", false, nil
+	}
+
+	val, err := sr.r.converter.Decode(field.raw(sr.data))
+	return val, true, err
+}
+
+func (sr *streamRow) Set(fld, val string) error {
+	return errors.New("read only row")
+}
@@ -0,0 +1,92 @@
+package dbf3
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	f := newTestFile(t, 3)
+	if err := f.DelRow(1); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(saveTestFile(t, f)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Rows() != 3 || r.RLen() != f.RLen() || r.HLen() != f.HLen() {
+		t.Fatalf("unexpected header: %d rows, %d, %d", r.Rows(), r.RLen(), r.HLen())
+	}
+	if !r.HasField("NAME") || r.HasField("OTHER") || len(r.Fields()) != 2 {
+		t.Fatal("unexpected fields")
+	}
+
+	for i := 0; i < 3; i++ {
+		row, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row.Index() != i {
+			t.Errorf("expected index %d, got %d", i, row.Index())
+		}
+		if row.Deleted() != (i == 1) {
+			t.Errorf("row %d: unexpected deleted flag", i)
+		}
+		if name, err := row.Get("NAME"); err != nil || name != "row"+string(rune('0'+i)) {
+			t.Errorf("row %d: unexpected name %q, %v", i, name, err)
+		}
+		if amount, err := row.Value("AMOUNT"); err != nil || amount != float64(i)+0.5 {
+			t.Errorf("row %d: unexpected amount %v, %v", i, amount, err)
+		}
+		if _, err := row.Get("OTHER"); err == nil {
+			t.Error("expected error for unknown field")
+		}
+		if row.Set("NAME", "x") == nil || row.Del() == nil {
+			t.Error("expected error for changing read only row")
+		}
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	data := saveTestFile(t, newTestFile(t, 2))
+	hlen := int(data[8]) | int(data[9])<<8
+	rlen := int(data[10]) | int(data[11])<<8
+
+	for _, tc := range []struct {
+		name   string
+		size   int
+		marker bool // EOF marker at the start of the second row
+	}{
+		{"partial row", hlen + rlen + rlen/2, false},
+		{"missing row", hlen + rlen, false},
+		{"EOF marker instead of row", hlen + 2*rlen, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := append([]byte(nil), data[:tc.size]...)
+			if tc.marker {
+				buf[hlen+rlen] = eof
+			}
+
+			r, err := NewReader(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Read(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Read(); err != io.ErrUnexpectedEOF {
+				t.Fatalf("expected unexpected EOF, got %v", err)
+			}
+		})
+	}
+
+	if _, err := NewReader(bytes.NewReader(data[:20])); err == nil {
+		t.Fatal("expected error for truncated header")
+	}
+}
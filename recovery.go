@@ -0,0 +1,120 @@
+package dbf3
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// openRecover opens damaged or non-conforming DBF:
+// fields are read until header terminator or first invalid
+// descriptor, rows count is computed from data size,
+// missing EOF marker and junk between header and data are ignored
+func openRecover(r io.Reader, o *options) (*file, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 32 {
+		return nil, errors.New("file is too short to contain header")
+	}
+
+	var found issues
+	hdr, err := readHeader(buf)
+	if err != nil {
+		// header length and row length are recomputed below
+		found.addHeader(err.Error())
+	}
+
+	// fields
+	expected := -1
+	if hdr.hlen >= 33 {
+		expected = (int(hdr.hlen) - 33) / 32
+	}
+	var fields []*field
+	fieldsIdx := make(map[string]int)
+	offset := 1 // fields starts after deletion flag
+	pos := 32
+	for ; pos+32 <= len(buf) && buf[pos] != hterm && len(fields) < maxFields; pos += 32 {
+		var fd fieldDescr
+		if err := fd.readFrom(buf[pos:]); err != nil || !validDescr(fd) {
+			break
+		}
+
+		fld, err := newField(fd, len(fields), offset)
+		if err != nil {
+			found.add(-1, len(fields), err.Error())
+			break
+		}
+		if _, exists := fieldsIdx[fld.Name()]; exists {
+			found.add(-1, fld.idx, "duplicate field name "+fld.Name())
+		} else {
+			fieldsIdx[fld.Name()] = fld.idx
+		}
+		fields = append(fields, fld)
+		offset += fld.Len()
+	}
+
+	dataStart := pos
+	if pos < len(buf) && buf[pos] == hterm {
+		dataStart++
+	} else {
+		found.addHeader("header terminator not found")
+	}
+	if expected != len(fields) {
+		found.addHeader("header length does not match fields count: " +
+			strconv.Itoa(expected) + " expected, " + strconv.Itoa(len(fields)) + " found")
+	}
+
+	// header may be followed by some data (e.g. backlink),
+	// so header length is used if it's possible
+	if int(hdr.hlen) > dataStart && int(hdr.hlen) <= len(buf) {
+		found.addHeader(strconv.Itoa(int(hdr.hlen)-dataStart) +
+			" bytes between header and data skipped")
+		dataStart = int(hdr.hlen)
+	} else if int(hdr.hlen) != dataStart {
+		found.addHeader("invalid header length " + strconv.Itoa(int(hdr.hlen)))
+	}
+	hdr.hlen = uint16(32 + len(fields)*32 + 1)
+
+	if int(hdr.rlen) != offset {
+		found.addHeader("row length does not match fields: " +
+			strconv.Itoa(int(hdr.rlen)) + " in header, " + strconv.Itoa(offset) + " by fields")
+		hdr.rlen = uint16(offset)
+	}
+
+	// rows
+	data := buf[dataStart:]
+	rlen := int(hdr.rlen)
+	rows := len(data) / rlen
+	switch {
+	case int(hdr.rows) <= rows && int(hdr.rows)*rlen < len(data) &&
+		data[int(hdr.rows)*rlen] == eof:
+		rows = int(hdr.rows)
+	case int(hdr.rows) == rows && rows*rlen == len(data):
+		found.addHeader("EOF marker not found")
+	default:
+		if rows*rlen < len(data) && (rows*rlen != len(data)-1 || data[len(data)-1] != eof) {
+			found.addHeader("incomplete last row skipped")
+		}
+		found.addHeader("rows count " + strconv.Itoa(int(hdr.rows)) +
+			" in header does not match data size, " + strconv.Itoa(rows) + " rows read")
+	}
+	hdr.rows = uint32(rows)
+
+	rowsData := make([]byte, rows*rlen+1)
+	copy(rowsData, data)
+	rowsData[len(rowsData)-1] = eof
+
+	o.applyLang(&hdr)
+	if o.issues != nil {
+		*o.issues = append(*o.issues, found...)
+	}
+
+	return o.newFile(hdr, fields, fieldsIdx, rowsData), nil
+}
+
+// validDescr checks field descriptor looks like a real one
+func validDescr(fd fieldDescr) bool {
+	return fd.name[0] != 0 && fd.typ >= 'A' && fd.typ <= 'Z'
+}
@@ -0,0 +1,104 @@
+package dbf3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestOpenRecovery(t *testing.T) {
+	good := saveTestFile(t, newTestFile(t, 5))
+	hlen := int(binary.LittleEndian.Uint16(good[8:]))
+
+	damage := func(fn func(data []byte) []byte) []byte {
+		return fn(append([]byte(nil), good...))
+	}
+
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		rows  int
+		issue string
+	}{
+		{
+			name: "valid file",
+			data: good,
+			rows: 5,
+		},
+		{
+			name:  "missing EOF marker",
+			data:  good[:len(good)-1],
+			rows:  5,
+			issue: "EOF marker not found",
+		},
+		{
+			name:  "incomplete last row",
+			data:  good[:len(good)-5],
+			rows:  4,
+			issue: "incomplete last row skipped",
+		},
+		{
+			name: "too big rows count",
+			data: damage(func(data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[4:], 9)
+				return data
+			}),
+			rows:  5,
+			issue: "rows count 9 in header does not match data size, 5 rows read",
+		},
+		{
+			name: "junk between header and data",
+			data: damage(func(data []byte) []byte {
+				binary.LittleEndian.PutUint16(data[8:], uint16(hlen+8))
+				junk := append([]byte("JUNKJUNK"), data[hlen:]...)
+				return append(data[:hlen], junk...)
+			}),
+			rows:  5,
+			issue: "8 bytes between header and data skipped",
+		},
+		{
+			name: "invalid row length",
+			data: damage(func(data []byte) []byte {
+				binary.LittleEndian.PutUint16(data[10:], 30)
+				return data
+			}),
+			rows:  5,
+			issue: "row length does not match fields: 30 in header, 21 by fields",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var found []Issue
+			f, err := Open(bytes.NewReader(tc.data), WithRecovery(&found))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Rows() != tc.rows {
+				t.Fatalf("expected %d rows, got %d", tc.rows, f.Rows())
+			}
+			for idx := 0; idx < tc.rows; idx++ {
+				expectValue(t, f, idx, "NAME", "row"+string(rune('0'+idx)))
+			}
+
+			if tc.issue == "" {
+				if len(found) != 0 {
+					t.Fatalf("unexpected issues %v", found)
+				}
+				return
+			}
+			for _, issue := range found {
+				if strings.Contains(issue.String(), tc.issue) {
+					return
+				}
+			}
+			t.Fatalf("issue %q not found in %v", tc.issue, found)
+		})
+	}
+}
+
+func TestOpenRecoveryTooShort(t *testing.T) {
+	var found []Issue
+	if _, err := Open(bytes.NewReader(make([]byte, 20)), WithRecovery(&found)); err == nil {
+		t.Fatal("expected error for too short file")
+	}
+}
@@ -0,0 +1,199 @@
+package dbf3
+
+import (
+	"errors"
+	"strconv"
+)
+
+// RepairOptions presents options of Repair
+type RepairOptions struct {
+	// Quarantine moves rows with invalid values into separate file
+	// instead of blanking invalid values
+	Quarantine bool
+}
+
+// RepairResult presents result of Repair
+type RepairResult struct {
+	Changes    []Issue // log of made changes
+	Quarantine File    // file with quarantined rows (nil if not requested)
+}
+
+// Repair fixes common corruptions of DBF: rows count, header and row
+// lengths, invalid deletion flags, invalid values of Numeric, Date
+// and Logical fields (blanked or quarantined) and EOF marker.
+// Row indexes in the log are indexes before repair.
+// Header and row lengths of disk-backed file cannot be fixed
+// in place, such file must be repaired in memory.
+// Repairs are not recorded in the history of edits,
+// so the history is cleared
+func Repair(f File, opts RepairOptions) (*RepairResult, error) {
+	ff, unlock, ok := acquire(f)
+	if !ok {
+		return nil, errors.New("unsupported file implementation")
+	}
+	defer unlock()
+	if ff.readOnly {
+		return nil, errors.New("read only file")
+	}
+
+	var changes issues
+	if err := ff.repairLayout(&changes); err != nil {
+		return nil, err
+	}
+	if ff.history != nil {
+		ff.history = newHistory(ff.history.limit)
+	}
+
+	result := &RepairResult{}
+	var quarantine *file
+	if opts.Quarantine {
+		quarantine = New(WithLang(ff.Lang()), WithTextConverter(ff.converterCtor)).(*file)
+		for _, fld := range ff.fields {
+			if err := quarantine.addField(fld.descr); err != nil {
+				return nil, err
+			}
+		}
+		result.Quarantine = quarantine
+	}
+
+	var bad []int
+	for row := 0; row < ff.Rows(); row++ {
+		data, err := ff.rowData(row)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, len(data))
+		copy(buf, data)
+
+		changed := false
+		if buf[0] != blank && buf[0] != deleted {
+			changes.add(row, -1, "invalid deletion flag 0x"+
+				strconv.FormatUint(uint64(buf[0]), 16)+" replaced by blank")
+			buf[0] = blank
+			changed = true
+		}
+
+		invalid := false
+		for _, fld := range ff.fields {
+			if err := checkValue(fld, buf); err == nil {
+				continue
+			}
+			invalid = true
+			if !opts.Quarantine {
+				changes.add(row, fld.idx, "invalid value blanked")
+				fld.setValue(buf, "")
+				changed = true
+			}
+		}
+
+		if invalid && opts.Quarantine {
+			changes.add(row, -1, "row with invalid values quarantined")
+			if err := quarantine.appendRow(buf); err != nil {
+				return nil, err
+			}
+			bad = append(bad, row)
+			continue
+		}
+
+		if changed {
+			if err := ff.putRow(row, buf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := ff.removeRows(bad); err != nil {
+		return nil, err
+	}
+
+	result.Changes = changes
+	if len(changes) > 0 {
+		ff.header.updateChanged()
+	}
+	return result, nil
+}
+
+// repairLayout fixes header length, row length, rows count and EOF marker
+func (f *file) repairLayout(changes *issues) error {
+	hlen := 32 + len(f.fields)*32 + 1
+	rlen := 1
+	for _, fld := range f.fields {
+		rlen += fld.Len()
+	}
+	if f.disk != nil {
+		// offsets of rows on disk depend on header and row lengths
+		if f.HLen() != hlen {
+			return errors.New("header length cannot be fixed for disk-backed file")
+		}
+		if f.RLen() != rlen {
+			return errors.New("row length cannot be fixed for disk-backed file")
+		}
+		return nil
+	}
+
+	if f.HLen() != hlen {
+		changes.addHeader("header length changed from " +
+			strconv.Itoa(f.HLen()) + " to " + strconv.Itoa(hlen))
+		f.header.hlen = uint16(hlen)
+	}
+
+	if f.RLen() != rlen {
+		changes.addHeader("row length changed from " +
+			strconv.Itoa(f.RLen()) + " to " + strconv.Itoa(rlen))
+		f.relayout(rlen)
+	}
+
+	if rows := (len(f.data) - 1) / f.RLen(); rows != f.Rows() {
+		changes.addHeader("rows count changed from " +
+			strconv.Itoa(f.Rows()) + " to " + strconv.Itoa(rows))
+		f.header.rows = uint32(rows)
+	}
+
+	if f.data[len(f.data)-1] != eof {
+		changes.addHeader("EOF marker restored")
+		f.data[len(f.data)-1] = eof
+	}
+	return nil
+}
+
+// relayout changes row length: rows are truncated
+// or padded by blanks to the new length
+func (f *file) relayout(rlen int) {
+	data := make([]byte, f.Rows()*rlen+1)
+	for row := 0; row < f.Rows(); row++ {
+		dst := data[row*rlen : (row+1)*rlen]
+		n := copy(dst, f.data[row*f.RLen():(row+1)*f.RLen()])
+		for idx := n; idx < rlen; idx++ {
+			dst[idx] = blank
+		}
+	}
+	data[len(data)-1] = eof
+	f.data = data
+	f.header.rlen = uint16(rlen)
+	f.layoutChanged()
+}
+
+// removeRows removes rows with specified (sorted) indexes
+func (f *file) removeRows(rows []int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	removed := 0
+	for row := rows[0]; row < f.Rows(); row++ {
+		if removed < len(rows) && rows[removed] == row {
+			removed++
+			continue
+		}
+
+		data, err := f.rowData(row)
+		if err != nil {
+			return err
+		}
+		if err := f.putRow(row-removed, data); err != nil {
+			return err
+		}
+	}
+
+	return f.truncateRows(f.Rows() - removed)
+}
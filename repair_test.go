@@ -0,0 +1,178 @@
+package dbf3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newRepairSeed returns file with invalid values in rows 1 and 3,
+// invalid deletion flag of row 2, wrong row length and without EOF marker
+func newRepairSeed(t *testing.T) []byte {
+	f := New()
+	if err := f.AddField("N", Numeric, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddField("D", Date, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := f.NewRow(); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(i, "N", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Set(1, "N", "1e5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(3, "D", "2020"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := saveTestFile(t, f)
+	data[f.HLen()+2*f.RLen()] = 'Z'
+	data[10]++
+	return data[:len(data)-1]
+}
+
+func TestRepair(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       RepairOptions
+		rows       int
+		quarantine int
+	}{
+		{"blank invalid values", RepairOptions{}, 4, 0},
+		{"quarantine invalid rows", RepairOptions{Quarantine: true}, 2, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Open(bytes.NewReader(newRepairSeed(t)), WithRecovery(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := Repair(f, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Changes) == 0 {
+				t.Fatal("expected changes")
+			}
+			if f.Rows() != tc.rows {
+				t.Fatalf("expected %d rows, got %d", tc.rows, f.Rows())
+			}
+			if tc.quarantine > 0 {
+				if result.Quarantine == nil || result.Quarantine.Rows() != tc.quarantine {
+					t.Fatalf("expected %d quarantined rows", tc.quarantine)
+				}
+			} else if result.Quarantine != nil {
+				t.Fatal("unexpected quarantine")
+			}
+
+			report, err := Check(bytes.NewReader(saveTestFile(t, f)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.OK() {
+				t.Fatalf("unexpected issues after repair: %v", report.Issues)
+			}
+			expectValue(t, f, 0, "N", "1")
+		})
+	}
+}
+
+func TestRepairHeaderLength(t *testing.T) {
+	data := fuzzSeedGap()
+
+	f, err := Open(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Repair(f, RepairOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 1 || f.HLen() != 32+4*32+1 {
+		t.Fatalf("unexpected changes %v", result.Changes)
+	}
+	expectValue(t, f, 0, "NAME", "first")
+	expectValue(t, f, 1, "AMOUNT", "-20.25")
+
+	// disk-backed file cannot be fixed in place
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := os.WriteFile(fileName, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenFileAt(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := Repair(d, RepairOptions{}); err == nil {
+		t.Fatal("expected error for disk-backed file")
+	}
+	if err := d.Set(0, "AMOUNT", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, g, 0, "NAME", "first")
+	expectValue(t, g, 0, "AMOUNT", "7")
+}
+
+func TestRepairUpdate(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := os.WriteFile(fileName, fuzzSeedGap(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Repair(f, RepairOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Check(bytes.NewReader(mustReadFile(t, fileName)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("unexpected issues after repair: %v", report.Issues)
+	}
+}
+
+func TestRepairClearsHistory(t *testing.T) {
+	f := newTestFile(t, 2, WithHistory(1<<20))
+	if err := f.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Repair(f, RepairOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Undo(); err == nil {
+		t.Fatal("expected empty history after repair")
+	}
+}
+
+func mustReadFile(t *testing.T, fileName string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
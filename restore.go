@@ -0,0 +1,95 @@
+package dbf3
+
+// restorePoint presents state of file, which can be restored
+// after changes. Rows are saved lazily, before they are changed
+type restorePoint struct {
+	header header
+	fields []field
+	rows   map[int][]byte // before-images of changed rows
+	data   []byte         // all rows data (saved before schema change)
+}
+
+func (f *file) restorePoint() *restorePoint {
+	rp := &restorePoint{
+		header: f.header,
+		fields: make([]field, len(f.fields)),
+		rows:   make(map[int][]byte),
+	}
+	for idx := range f.fields {
+		rp.fields[idx] = *f.fields[idx]
+	}
+	return rp
+}
+
+// saveRow saves before-image of row with specified index
+func (rp *restorePoint) saveRow(f *file, idx int) error {
+	if idx >= int(rp.header.rows) || rp.data != nil {
+		// new rows are removed on restore and rows
+		// changed after schema change are restored with data
+		return nil
+	}
+	if _, ok := rp.rows[idx]; ok {
+		return nil
+	}
+
+	data, err := f.rowData(idx)
+	if err != nil {
+		return err
+	}
+	rp.rows[idx] = append([]byte(nil), data...)
+	return nil
+}
+
+// saveData saves all rows data of in-memory file before schema change.
+// Saved before-images have the same layout, so they are applied
+// to the data (they cannot be put into rows of another layout)
+func (rp *restorePoint) saveData(f *file) {
+	if rp.data != nil || f.disk != nil {
+		return
+	}
+
+	rp.data = append([]byte(nil), f.data...)
+	rlen := int(f.header.rlen)
+	for idx, data := range rp.rows {
+		copy(rp.data[idx*rlen:], data)
+	}
+	rp.rows = nil
+}
+
+// restore restores state of file saved by restore point
+func (f *file) restore(rp *restorePoint) error {
+	rows := int(f.header.rows)
+	if rp.data != nil {
+		f.data = rp.data
+		rows = (len(rp.data) - 1) / int(rp.header.rlen)
+		f.layoutChanged()
+	}
+
+	f.fields = make([]*field, len(rp.fields))
+	f.fieldsIdx = make(map[string]int, len(rp.fields))
+	for idx := range rp.fields {
+		fld := rp.fields[idx]
+		f.fields[idx] = &fld
+		f.fieldsIdx[fld.name] = idx
+	}
+
+	hdr := rp.header
+	hdr.rows = uint32(rows)
+	f.header = hdr
+	if rows > int(rp.header.rows) {
+		if err := f.truncateRows(int(rp.header.rows)); err != nil {
+			return err
+		}
+	}
+	for idx, data := range rp.rows {
+		if err := f.putRow(idx, data); err != nil {
+			return err
+		}
+	}
+
+	f.header = rp.header
+	if f.disk != nil {
+		return f.disk.writeHeader()
+	}
+	return nil
+}
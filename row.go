@@ -1,7 +1,7 @@
 package dbf3
 
 type row struct {
-	f   *file
+	f   File
 	idx int
 }
 
@@ -10,8 +10,13 @@ const (
 	deleted = 0x2A
 )
 
+func (r *row) Index() int {
+	return r.idx
+}
+
 func (r *row) Deleted() bool {
-	return r.f.data[r.offset()] == deleted
+	deleted, _ := r.f.Deleted(r.idx)
+	return deleted
 }
 
 func (r *row) Del() error {
@@ -22,10 +27,20 @@ func (r *row) Get(fld string) (string, error) {
 	return r.f.Get(r.idx, fld)
 }
 
-func (r *row) Set(fld, val string) error {
-	return r.f.Set(r.idx, fld, val)
+func (r *row) Value(fld string) (interface{}, error) {
+	return r.f.Value(r.idx, fld)
 }
 
-func (r *row) offset() int {
-	return r.f.RLen() * r.idx
+func (r *row) rawChar(fld string) (string, bool, error) {
+	rf, ok := r.f.(interface {
+		rawChar(row int, field string) (string, bool, error)
+	})
+	if !ok {
+		return "", false, nil
+	}
+	return rf.rawChar(r.idx, fld)
+}
+
+func (r *row) Set(fld, val string) error {
+	return r.f.Set(r.idx, fld, val)
 }
@@ -0,0 +1,289 @@
+package dbf3
+
+import (
+	"errors"
+	"strings"
+)
+
+// FieldSpec presents specification of field
+type FieldSpec struct {
+	Name string
+	Type FieldType
+	Len  int  // full length (can be omitted for Date and Logical fields)
+	Dec  byte // decimal count (Numeric fields only)
+}
+
+// Schema presents layout of file: ordered fields and language driver
+type Schema struct {
+	Lang   LangID
+	Fields []FieldSpec
+}
+
+// SchemaDiff presents differences between two schemas
+type SchemaDiff struct {
+	Added       []FieldSpec   // fields missing in the first schema
+	Removed     []FieldSpec   // fields missing in the second schema
+	Changed     []FieldChange // fields with different type, length or decimal count
+	Reordered   bool          // order of common fields differs
+	LangChanged bool          // language drivers differ
+}
+
+// FieldChange presents field changed between schemas
+type FieldChange struct {
+	From FieldSpec
+	To   FieldSpec
+}
+
+// Empty checks if schemas are equal
+func (d SchemaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		!d.Reordered && !d.LangChanged
+}
+
+// descr validates field specification and creates field descriptor
+func (s FieldSpec) descr() (fieldDescr, error) {
+	length, dec := byte(s.Len), s.Dec
+	if s.Type == Character {
+		// length of character fields stored in both bytes
+		if s.Len < 0 || s.Len > 0xFFFF {
+			return fieldDescr{}, errors.New("exceeded max field length")
+		}
+		dec = byte(s.Len >> 8)
+	} else if s.Len < 0 || s.Len > 0xFF {
+		return fieldDescr{}, errors.New("exceeded max field length")
+	}
+	return newFieldDescr(s.Name, s.Type, length, dec)
+}
+
+// normalize returns specification with actual name and length
+// (or specification itself, if it's invalid)
+func (s FieldSpec) normalize() FieldSpec {
+	dt, err := s.descr()
+	if err != nil {
+		return s
+	}
+	fld, err := newField(dt, 0, 1)
+	if err != nil {
+		return s
+	}
+	return specOf(fld)
+}
+
+func specOf(fld *field) FieldSpec {
+	spec := FieldSpec{Name: fld.Name(), Type: fld.Type(), Len: fld.Len()}
+	if fld.Type() != Character {
+		spec.Dec = fld.Dec()
+	}
+	return spec
+}
+
+// NewFromSchema creates new empty DBF file with specified schema
+func NewFromSchema(schema Schema, opts ...Option) (File, error) {
+	opts = append([]Option{WithLang(schema.Lang)}, opts...)
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	f := o.newFile(newHeader(o.lang), nil, make(map[string]int), []byte{eof})
+	for _, spec := range schema.Fields {
+		dt, err := spec.descr()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := f.fieldsIdx[dt.nameString()]; exists {
+			return nil, errors.New("field already exists")
+		}
+		if err := f.addField(dt); err != nil {
+			return nil, err
+		}
+	}
+	return o.wrap(f), nil
+}
+
+func (f *file) Schema() Schema {
+	schema := Schema{
+		Lang:   f.Lang(),
+		Fields: make([]FieldSpec, len(f.fields)),
+	}
+	for idx, fld := range f.fields {
+		schema.Fields[idx] = specOf(fld)
+	}
+	return schema
+}
+
+// Diff compares schemas. Fields are matched by name
+func Diff(a, b Schema) SchemaDiff {
+	var d SchemaDiff
+	d.LangChanged = a.Lang != b.Lang
+
+	inA := make(map[string]FieldSpec, len(a.Fields))
+	for _, spec := range a.Fields {
+		spec = spec.normalize()
+		inA[spec.Name] = spec
+	}
+	inB := make(map[string]bool, len(b.Fields))
+	var common []string // common fields in order of b
+	for _, spec := range b.Fields {
+		spec = spec.normalize()
+		inB[spec.Name] = true
+		from, ok := inA[spec.Name]
+		if !ok {
+			d.Added = append(d.Added, spec)
+			continue
+		}
+		common = append(common, spec.Name)
+		if from != spec {
+			d.Changed = append(d.Changed, FieldChange{From: from, To: spec})
+		}
+	}
+
+	idx := 0
+	for _, spec := range a.Fields {
+		spec = spec.normalize()
+		if !inB[spec.Name] {
+			d.Removed = append(d.Removed, spec)
+			continue
+		}
+		if common[idx] != spec.Name {
+			d.Reordered = true
+		}
+		idx++
+	}
+	return d
+}
+
+// Migrate changes layout of file to target schema: removes, alters,
+// adds and reorders fields, keeping values of common fields
+// (see AlterField for conversion of values). Language driver is
+// changed if it's specified by target schema (values are not
+// re-encoded). File is changed only if all steps succeeded
+func Migrate(f File, target Schema) ([]Issue, error) {
+	ff, unlock, ok := acquire(f)
+	if !ok {
+		return nil, errors.New("unsupported file implementation")
+	}
+	defer unlock()
+
+	return ff.migrate(target)
+}
+
+func (f *file) migrate(target Schema) ([]Issue, error) {
+	names := make([]string, len(target.Fields))
+	targetIdx := make(map[string]int, len(target.Fields))
+	for idx, spec := range target.Fields {
+		if _, err := spec.descr(); err != nil {
+			return nil, errors.New("field " + spec.Name + ": " + err.Error())
+		}
+		spec = spec.normalize()
+		if _, exists := targetIdx[spec.Name]; exists {
+			return nil, errors.New("duplicate field name " + spec.Name)
+		}
+		names[idx] = spec.Name
+		targetIdx[spec.Name] = idx
+	}
+
+	current := f.Schema()
+	if target.Lang == LangDefault {
+		target.Lang = current.Lang
+	}
+	diff := Diff(current, target)
+	if diff.Empty() {
+		return nil, nil
+	}
+	if f.readOnly {
+		return nil, errors.New("read only file")
+	}
+	if f.disk != nil || f.view != nil {
+		return nil, errors.New("not supported for disk-backed file")
+	}
+
+	// layout is changed in copy of file, which replaces file data
+	// only if all steps succeeded
+	c := f.copyLayout()
+	c.data = append([]byte(nil), f.data...)
+
+	var found issues
+	for _, spec := range diff.Removed {
+		if err := c.DelField(spec.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range diff.Changed {
+		dt, _ := change.To.descr()
+		changed, err := c.AlterField(change.To.Name, change.To.Type, dt.len, dt.dec)
+		if err != nil {
+			return nil, errors.New("field " + change.To.Name + ": " + err.Error())
+		}
+		for _, issue := range changed {
+			issue.Field = targetIdx[change.To.Name]
+			found = append(found, issue)
+		}
+	}
+	for _, spec := range diff.Added {
+		dt, _ := spec.descr()
+		if err := c.addField(dt); err != nil {
+			return nil, errors.New("field " + spec.Name + ": " + err.Error())
+		}
+	}
+	if err := c.ReorderFields(names); err != nil {
+		return nil, err
+	}
+	before := f.restorePoint()
+	before.data = f.data
+	f.header.hlen = c.header.hlen
+	f.header.rlen = c.header.rlen
+	f.header.lang = byte(target.Lang)
+	f.header.updateChanged()
+	f.fields = c.fields
+	f.fieldsIdx = c.fieldsIdx
+	f.data = c.data
+	f.converter = f.converterCtor(f.Lang())
+	f.layoutChanged()
+	after := f.restorePoint()
+	after.data = f.data
+
+	f.record(migrateEdit(before, after))
+	f.fireSchemaChange()
+	return found, nil
+}
+
+// migrateEdit creates edit of layout change
+func migrateEdit(before, after *restorePoint) edit {
+	restore := func(rp *restorePoint) func(f *file) error {
+		return func(f *file) error {
+			if err := f.restore(rp); err != nil {
+				return err
+			}
+			f.converter = f.converterCtor(f.Lang())
+			f.fireSchemaChange()
+			return nil
+		}
+	}
+	return edit{
+		undo: restore(before),
+		redo: restore(after),
+		size: len(before.data) + len(after.data),
+	}
+}
+
+// String returns short description of differences
+func (d SchemaDiff) String() string {
+	var parts []string
+	for _, spec := range d.Added {
+		parts = append(parts, "+"+spec.Name)
+	}
+	for _, spec := range d.Removed {
+		parts = append(parts, "-"+spec.Name)
+	}
+	for _, change := range d.Changed {
+		parts = append(parts, "~"+change.To.Name)
+	}
+	if d.Reordered {
+		parts = append(parts, "reordered")
+	}
+	if d.LangChanged {
+		parts = append(parts, "language changed")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,172 @@
+package dbf3
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewFromSchema(t *testing.T) {
+	schema := Schema{Lang: Lang3, Fields: []FieldSpec{
+		{Name: "ID", Type: Numeric, Len: 10},
+		{Name: "NOTE", Type: Character, Len: 300},
+		{Name: "BIRTH", Type: Date},
+	}}
+	f, err := NewFromSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Schema{Lang: Lang3, Fields: []FieldSpec{
+		{Name: "ID", Type: Numeric, Len: 10},
+		{Name: "NOTE", Type: Character, Len: 300},
+		{Name: "BIRTH", Type: Date, Len: 8},
+	}}
+	if got := f.Schema(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected schema %+v", got)
+	}
+	if d := Diff(schema, f.Schema()); !d.Empty() {
+		t.Fatalf("expected equal schemas, got %v", d)
+	}
+
+	schema.Fields = append(schema.Fields, FieldSpec{Name: "ID", Type: Logical})
+	if _, err := NewFromSchema(schema); err == nil {
+		t.Fatal("expected error for duplicate field")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := Schema{Fields: []FieldSpec{
+		{Name: "A", Type: Character, Len: 5},
+		{Name: "B", Type: Numeric, Len: 5},
+		{Name: "C", Type: Logical},
+	}}
+	b := Schema{Lang: Lang3, Fields: []FieldSpec{
+		{Name: "C", Type: Logical, Len: 1},
+		{Name: "B", Type: Numeric, Len: 6, Dec: 1},
+		{Name: "D", Type: Date},
+	}}
+
+	d := Diff(a, b)
+	want := SchemaDiff{
+		Added:       []FieldSpec{{Name: "D", Type: Date, Len: 8}},
+		Removed:     []FieldSpec{{Name: "A", Type: Character, Len: 5}},
+		Changed:     []FieldChange{{From: FieldSpec{Name: "B", Type: Numeric, Len: 5}, To: FieldSpec{Name: "B", Type: Numeric, Len: 6, Dec: 1}}},
+		Reordered:   true,
+		LangChanged: true,
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+}
+
+// migrateTarget changes all parts of layout of test file
+var migrateTarget = Schema{Lang: Lang3, Fields: []FieldSpec{
+	{Name: "AMOUNT", Type: Numeric, Len: 10, Dec: 3},
+	{Name: "NAME", Type: Character, Len: 10},
+	{Name: "FLAG", Type: Logical},
+}}
+
+func TestMigrate(t *testing.T) {
+	f := newTestFile(t, 3, WithHistory(0))
+	before := saveTestFile(t, f)
+
+	found, err := Migrate(f, migrateTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("unexpected issues %v", found)
+	}
+	if d := Diff(f.Schema(), migrateTarget); !d.Empty() {
+		t.Fatalf("file is not migrated: %v", d)
+	}
+	expectValue(t, f, 2, "NAME", "row2")
+	expectValue(t, f, 2, "AMOUNT", "2.500")
+	after := saveTestFile(t, f)
+
+	if err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(before)) {
+		t.Fatal("file is not restored by undo")
+	}
+	if err := f.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutDate(saveTestFile(t, f)), withoutDate(after)) {
+		t.Fatal("file is not migrated by redo")
+	}
+
+	// nothing to change
+	if found, err := Migrate(f, migrateTarget); err != nil || found != nil {
+		t.Fatalf("unexpected result %v, %v", found, err)
+	}
+}
+
+func TestMigrateFailed(t *testing.T) {
+	f := newTestFile(t, 3)
+	before := saveTestFile(t, f)
+
+	target := Schema{Fields: []FieldSpec{
+		{Name: "NAME", Type: Character, Len: 10},
+		{Name: "AMOUNT", Type: Numeric, Len: 10},
+		{Name: "HUGE", Type: Character, Len: 0xFFFF},
+	}}
+	if _, err := Migrate(f, target); err == nil {
+		t.Fatal("expected error for exceeded row length")
+	}
+	if !bytes.Equal(saveTestFile(t, f), before) {
+		t.Fatal("file is changed by failed migration")
+	}
+}
+
+func TestMigrateUpdate(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	// row length of target schema is the same
+	target := Schema{Fields: []FieldSpec{
+		{Name: "AMOUNT", Type: Numeric, Len: 10, Dec: 3},
+		{Name: "NAME", Type: Character, Len: 10},
+	}}
+	f, err := OpenUpdate(fileName, WithHistory(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(f, target); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expectSynced(t, f, fileName)
+
+	// undone migration is written too
+	if err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expectSynced(t, f, fileName)
+	if err := f.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(g.Schema(), target); !d.Empty() {
+		t.Fatalf("file is not migrated: %v", d)
+	}
+	expectValue(t, g, 1, "NAME", "row1")
+	expectValue(t, g, 1, "AMOUNT", "1.500")
+}
@@ -0,0 +1,187 @@
+package dbf3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Storage presents storage of files,
+// used by OpenFile, OpenArchive and SaveFile
+type Storage interface {
+	// Open opens file for reading
+	Open(name string) (io.ReadCloser, error)
+	// Create creates or truncates file for writing
+	Create(name string) (io.WriteCloser, error)
+	// Rename renames (moves) file, replacing existing one
+	Rename(oldName, newName string) error
+	// Remove removes file
+	Remove(name string) error
+}
+
+// OSStorage presents storage of operating system files
+var OSStorage Storage = osStorage{}
+
+type osStorage struct{}
+
+func (osStorage) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (osStorage) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+func (osStorage) Remove(name string) error                   { return os.Remove(name) }
+
+func (osStorage) Rename(oldName, newName string) error {
+	if err := os.Rename(oldName, newName); err != nil {
+		return err
+	}
+
+	// make rename durable (directories can't be synced on some systems)
+	if dir, err := os.Open(filepath.Dir(newName)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// keepMode copies permissions and owner of existing file
+// to another file
+func (osStorage) keepMode(from, to string) error {
+	st, err := os.Stat(from)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(to, st.Mode().Perm()); err != nil {
+		return err
+	}
+	return keepOwner(to, st)
+}
+
+// saveAtomic writes file into temporary file next to it,
+// syncs it (if it's supported by storage) and renames it
+// over the original file, so the original file is either
+// kept untouched or replaced completely. Permissions and
+// owner of modeOf file (usually the original file) are kept,
+// if it's supported by storage
+func saveAtomic(s Storage, name, modeOf string, write func(io.Writer) error) error {
+	tmp := name + "." + strconv.FormatInt(time.Now().UnixNano(), 36) + ".tmp"
+	w, err := s.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if mk, ok := s.(interface{ keepMode(from, to string) error }); ok {
+		err = mk.keepMode(modeOf, tmp)
+	}
+	if err == nil {
+		err = write(w)
+	}
+	if sw, ok := w.(interface{ Sync() error }); ok && err == nil {
+		err = sw.Sync()
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = s.Rename(tmp, name)
+	}
+	if err != nil {
+		s.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// backupFile copies file into .bak file (if file exists)
+func backupFile(s Storage, name string) error {
+	r, err := s.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return saveAtomic(s, companionName(name, ".bak"), name, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemStorage creates in-memory storage.
+// Created files become visible when they are closed
+func NewMemStorage() Storage {
+	return &memStorage{files: make(map[string][]byte)}
+}
+
+func (ms *memStorage) Open(name string) (io.ReadCloser, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, ok := ms.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return memReader{bytes.NewReader(data)}, nil
+}
+
+func (ms *memStorage) Create(name string) (io.WriteCloser, error) {
+	return &memWriter{ms: ms, name: name}, nil
+}
+
+func (ms *memStorage) Rename(oldName, newName string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, ok := ms.files[oldName]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	delete(ms.files, oldName)
+	ms.files[newName] = data
+	return nil
+}
+
+func (ms *memStorage) Remove(name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(ms.files, name)
+	return nil
+}
+
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error { return nil }
+
+type memWriter struct {
+	bytes.Buffer
+	ms   *memStorage
+	name string
+}
+
+func (mw *memWriter) Close() error {
+	mw.ms.mu.Lock()
+	defer mw.ms.mu.Unlock()
+
+	mw.ms.files[mw.name] = mw.Bytes()
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package dbf3
+
+import "os"
+
+// keepOwner changes owner of file to owner of another file.
+// Not supported on this platform
+func keepOwner(name string, of os.FileInfo) error {
+	return nil
+}
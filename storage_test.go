@@ -0,0 +1,153 @@
+package dbf3
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMemStorage(t *testing.T) {
+	s := NewMemStorage()
+
+	w, err := s.Create("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open("a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("file must not be visible before Close, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Rename("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open("a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+	r, err := s.Open("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "data" {
+		t.Fatalf("unexpected data %q, %v", data, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{s.Remove("b"), s.Rename("b", "c")} {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected not exist error, got %v", err)
+		}
+	}
+}
+
+func TestWithStorage(t *testing.T) {
+	s := NewMemStorage()
+	if err := newTestFile(t, 2, WithStorage(s)).SaveFile("table.dbf"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFile("table.dbf", WithStorage(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, f, 1, "NAME", "row1")
+
+	if _, err := OpenFile("table.dbf"); err == nil {
+		t.Fatal("expected error for file missing in OS storage")
+	}
+	if _, err := OpenArchive("table.dbf", "", WithStorage(s)); err == nil {
+		t.Fatal("expected error for not archived table")
+	}
+}
+
+func TestSaveFileKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not supported")
+	}
+
+	fileName := filepath.Join(t.TempDir(), "table.dbf")
+	f := newTestFile(t, 1, WithBackup())
+	if err := f.SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(fileName, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{fileName, companionName(fileName, ".bak")} {
+		st, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != 0o600 {
+			t.Errorf("%s: expected mode 0600, got %o", name, st.Mode().Perm())
+		}
+	}
+}
+
+func TestSaveFileOpened(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "table.dbf")
+	if err := newTestFile(t, 2).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, open := range map[string]func(string, ...Option) (File, error){
+		"update": OpenUpdate,
+		"disk":   OpenFileAt,
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := open(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if err := f.SaveFile(fileName); err == nil {
+				t.Fatal("expected error for saving file over itself")
+			}
+
+			copyName := filepath.Join(dir, name+".dbf")
+			if err := f.SaveFile(copyName); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Set(0, "NAME", name); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			g, err := OpenFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectValue(t, g, 0, "NAME", name)
+			c, err := OpenFile(copyName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Rows() != 2 {
+				t.Fatalf("expected 2 rows in copy, got %d", c.Rows())
+			}
+		})
+	}
+}
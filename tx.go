@@ -0,0 +1,204 @@
+package dbf3
+
+import (
+	"errors"
+	"math"
+)
+
+type tx struct {
+	f    *file
+	lock func() func() // locks file for commit
+
+	ops    []func(f *file, rp *restorePoint) error
+	rows   int            // rows count of file when transaction began
+	count  int            // rows count after buffered changes
+	fields map[string]int // fields lengths after buffered changes
+	done   bool
+}
+
+func (f *file) Begin() Tx {
+	return f.begin(func() func() { return func() {} })
+}
+
+func (f *file) begin(lock func() func()) *tx {
+	t := &tx{
+		f:      f,
+		lock:   lock,
+		rows:   f.Rows(),
+		count:  f.Rows(),
+		fields: make(map[string]int, len(f.fields)),
+	}
+	for _, fld := range f.fields {
+		t.fields[fld.Name()] = fld.Len()
+	}
+	return t
+}
+
+func (t *tx) NewRow() (int, error) {
+	if t.done {
+		return 0, errors.New("transaction is finished")
+	}
+	if int64(t.count) >= math.MaxUint32 {
+		return 0, errors.New("cannot add more rows")
+	}
+
+	t.ops = append(t.ops, func(f *file, rp *restorePoint) error {
+		_, err := f.NewRow()
+		return err
+	})
+	t.count++
+	return t.count - 1, nil
+}
+
+func (t *tx) DelRow(idx int) error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	if idx < 0 || idx >= t.count {
+		return errors.New("out of range")
+	}
+
+	t.ops = append(t.ops, func(f *file, rp *restorePoint) error {
+		if err := rp.saveRow(f, idx); err != nil {
+			return err
+		}
+		return f.DelRow(idx)
+	})
+	return nil
+}
+
+func (t *tx) AddField(name string, typ FieldType, length, dec byte) error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	if t.f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+	dt, err := newFieldDescr(name, typ, length, dec)
+	if err != nil {
+		return err
+	}
+	if _, exists := t.fields[dt.nameString()]; exists {
+		return errors.New("field already exists")
+	}
+	fld, err := newField(dt, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	t.ops = append(t.ops, func(f *file, rp *restorePoint) error {
+		rp.saveData(f)
+		return f.AddField(name, typ, length, dec)
+	})
+	t.fields[fld.Name()] = fld.Len()
+	return nil
+}
+
+func (t *tx) DelField(field string) error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	if t.f.disk != nil {
+		return errors.New("not supported for disk-backed file")
+	}
+	if _, ok := t.fields[field]; !ok {
+		return errors.New("field not found")
+	}
+
+	t.ops = append(t.ops, func(f *file, rp *restorePoint) error {
+		rp.saveData(f)
+		return f.DelField(field)
+	})
+	delete(t.fields, field)
+	return nil
+}
+
+func (t *tx) Set(row int, field, value string) error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	if row < 0 || row >= t.count {
+		return errors.New("out of range")
+	}
+	length, ok := t.fields[field]
+	if !ok {
+		return errors.New("field not found")
+	}
+	cval, err := t.f.converter.Encode(value)
+	if err != nil {
+		return err
+	}
+	if len(cval) > length {
+		return errors.New("value larger than the field length")
+	}
+
+	t.ops = append(t.ops, func(f *file, rp *restorePoint) error {
+		if err := rp.saveRow(f, row); err != nil {
+			return err
+		}
+		return f.Set(row, field, value)
+	})
+	return nil
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	t.done = true
+	defer t.lock()()
+
+	f := t.f
+	if f.readOnly {
+		return errors.New("read only file")
+	}
+	unlock, err := f.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if f.Rows() != t.rows {
+		return errors.New("file changed after transaction began")
+	}
+
+	rp := f.restorePoint()
+	mark := f.history.len()
+	if f.disk != nil {
+		f.header.transaction = 1
+		if err := f.disk.writeHeader(); err != nil {
+			f.header.transaction = 0
+			return err
+		}
+	}
+
+	// callbacks are not called for changes, which are rolled back
+	f.hooks.deferCalls()
+	for _, op := range t.ops {
+		if err := op(f, rp); err != nil {
+			f.hooks.finish(false)
+			f.history.discard(mark)
+			if rerr := f.restore(rp); rerr != nil {
+				return errors.New(err.Error() + " (rollback failed: " + rerr.Error() + ")")
+			}
+			return err
+		}
+	}
+
+	f.hooks.finish(true)
+
+	if f.disk != nil {
+		f.header.transaction = 0
+		return f.Flush()
+	}
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return errors.New("transaction is finished")
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
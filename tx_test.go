@@ -0,0 +1,186 @@
+package dbf3
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	f := newTestFile(t, 2)
+	var sets, newRows, schemas int
+	f.OnSet(func(row int, field, old, new string) { sets++ })
+	f.OnNewRow(func(row int) { newRows++ })
+	f.OnSchemaChange(func(fields []Field) { schemas++ })
+
+	tx := f.Begin()
+	idx, err := tx.NewRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx != 2 {
+		t.Fatalf("expected new row 2, got %d", idx)
+	}
+	if err := tx.AddField("NOTE", Character, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(idx, "NOTE", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DelRow(0); err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 2 {
+		t.Fatal("changes must be buffered until commit")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Rows() != 3 || len(f.Fields()) != 3 {
+		t.Fatalf("unexpected rows %d and fields %d", f.Rows(), len(f.Fields()))
+	}
+	expectValue(t, f, 2, "NOTE", "new")
+	expectValue(t, f, 1, "NAME", "row1")
+	if del, _ := f.Deleted(0); !del {
+		t.Fatal("expected row 0 to be deleted")
+	}
+	if sets != 1 || newRows != 1 || schemas != 1 {
+		t.Fatalf("unexpected callbacks: %d sets, %d new rows, %d schema changes",
+			sets, newRows, schemas)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected error for finished transaction")
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	f := newTestFile(t, 2)
+	before := saveTestFile(t, f)
+
+	tx := f.Begin()
+	if err := tx.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(0, "NAME", "changed"); err == nil {
+		t.Fatal("expected error for finished transaction")
+	}
+	if !bytes.Equal(saveTestFile(t, f), before) {
+		t.Fatal("file changed by rolled back transaction")
+	}
+}
+
+func TestTxFailedCommit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		build func(tx Tx) error
+	}{
+		{"rows", func(tx Tx) error {
+			if err := tx.Set(0, "NAME", "changed"); err != nil {
+				return err
+			}
+			if _, err := tx.NewRow(); err != nil {
+				return err
+			}
+			return tx.Set(1, "AMOUNT", "9")
+		}},
+		{"schema change", func(tx Tx) error {
+			if err := tx.Set(0, "NAME", "before"); err != nil {
+				return err
+			}
+			if err := tx.AddField("NOTE", Character, 5, 0); err != nil {
+				return err
+			}
+			if err := tx.Set(0, "NAME", "after"); err != nil {
+				return err
+			}
+			if err := tx.Set(1, "NOTE", "note"); err != nil {
+				return err
+			}
+			return tx.DelField("AMOUNT")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newTestFile(t, 2)
+			before := saveTestFile(t, f)
+			var calls int
+			f.OnSet(func(row int, field, old, new string) { calls++ })
+			f.OnSchemaChange(func(fields []Field) { calls++ })
+
+			tx := f.Begin()
+			if err := tc.build(tx); err != nil {
+				t.Fatal(err)
+			}
+			// the second deletion of the same row fails
+			if err := tx.DelRow(1); err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.DelRow(1); err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Commit(); err == nil {
+				t.Fatal("expected error")
+			}
+
+			if !bytes.Equal(saveTestFile(t, f), before) {
+				t.Fatal("file is not restored")
+			}
+			expectValue(t, f, 1, "NAME", "row1")
+			if calls != 0 {
+				t.Fatalf("expected no callbacks for rolled back changes, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestTxDisk(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 2).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFileAt(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tx := f.Begin()
+	if err := tx.AddField("NOTE", Character, 5, 0); err == nil {
+		t.Fatal("expected error for disk-backed file")
+	}
+	if err := tx.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DelRow(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DelRow(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected error")
+	}
+	expectValue(t, f, 0, "NAME", "row0")
+
+	tx = f.Begin()
+	if err := tx.Set(0, "NAME", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := mustReadFile(t, fileName)
+	if saved[14] != 0 {
+		t.Fatal("expected transaction flag to be cleared")
+	}
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, g, 0, "NAME", "changed")
+	expectValue(t, g, 1, "NAME", "row1")
+}
@@ -0,0 +1,194 @@
+package dbf3
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+)
+
+// OpenUpdate opens DBF from file for update. Whole file is loaded
+// into memory, but changes are written back by Flush incrementally:
+// only changed header, fields descriptors and rows.
+// File must be closed by Close method
+func OpenUpdate(fileName string, opts ...Option) (File, error) {
+	if err := recoverJournal(fileName, false); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	f, err := open(fd, o)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	f.closer = fd
+	if o.journal {
+		f.journal = newJournal(fd, fileName)
+		f.upd = newUpdate(f.journal, f)
+	} else {
+		f.upd = newUpdate(fd, f)
+	}
+	return o.wrap(f), nil
+}
+
+// update presents changes tracking of file opened for update
+type update struct {
+	rw ReaderWriterAt
+
+	// state of the file at the moment of last flush
+	header header
+	fields []fieldDescr
+
+	rows map[int]struct{} // changed rows
+	all  bool             // all rows changed (e.g. by fields layout change)
+}
+
+func newUpdate(rw ReaderWriterAt, f *file) *update {
+	u := &update{rw: rw}
+	u.reset(f)
+	return u
+}
+
+func (u *update) reset(f *file) {
+	u.header = f.header
+	u.fields = make([]fieldDescr, len(f.fields))
+	for idx := range f.fields {
+		u.fields[idx] = f.fields[idx].descr
+	}
+	u.rows = make(map[int]struct{})
+	u.all = false
+}
+
+func (u *update) markRow(idx int) {
+	u.rows[idx] = struct{}{}
+}
+
+func (u *update) markAll() {
+	u.all = true
+}
+
+func (u *update) fieldsChanged(f *file) bool {
+	if len(u.fields) != len(f.fields) {
+		return true
+	}
+	for idx := range f.fields {
+		if u.fields[idx] != f.fields[idx].descr {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *update) flush(f *file) error {
+	if u.all || u.header.hlen != f.header.hlen || u.header.rlen != f.header.rlen {
+		// rows layout changed, so whole file must be rewritten
+		if err := u.rewrite(f); err != nil {
+			return err
+		}
+		u.reset(f)
+		return nil
+	}
+
+	if err := u.writeRows(f); err != nil {
+		return err
+	}
+
+	if u.header.rows != f.header.rows {
+		end := int64(f.HLen()) + int64(f.Rows())*int64(f.RLen())
+		if _, err := u.rw.WriteAt([]byte{eof}, end); err != nil {
+			return err
+		}
+		if f.header.rows < u.header.rows {
+			if err := truncate(u.rw, end+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if u.fieldsChanged(f) {
+		buf := make([]byte, len(f.fields)*32)
+		for idx := range f.fields {
+			f.fields[idx].descr.writeTo(buf[idx*32:])
+		}
+		if _, err := u.rw.WriteAt(buf, 32); err != nil {
+			return err
+		}
+	}
+
+	if u.header != f.header {
+		if err := writeHeaderAt(u.rw, &f.header); err != nil {
+			return err
+		}
+	}
+
+	u.reset(f)
+	return nil
+}
+
+// writeRows writes changed rows joined into continuous ranges
+func (u *update) writeRows(f *file) error {
+	rows := make([]int, 0, len(u.rows))
+	for idx := range u.rows {
+		if idx < f.Rows() {
+			rows = append(rows, idx)
+		}
+	}
+	sort.Ints(rows)
+
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && rows[end] == rows[end-1]+1 {
+			end++
+		}
+
+		from, to := rows[start]*f.RLen(), (rows[end-1]+1)*f.RLen()
+		offset := int64(f.HLen()) + int64(from)
+		if _, err := u.rw.WriteAt(f.data[from:to], offset); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (u *update) rewrite(f *file) error {
+	var buf bytes.Buffer
+	if err := f.Save(&buf); err != nil {
+		return err
+	}
+	if _, err := u.rw.WriteAt(buf.Bytes(), 0); err != nil {
+		return err
+	}
+	return truncate(u.rw, int64(buf.Len()))
+}
+
+// truncate changes size of storage if it's supported
+func truncate(rw ReaderWriterAt, size int64) error {
+	if t, ok := rw.(interface{ Truncate(int64) error }); ok {
+		return t.Truncate(size)
+	}
+	return nil
+}
+
+// writeHeaderAt writes header (without fields) keeping reserved bytes
+func writeHeaderAt(rw ReaderWriterAt, h *header) error {
+	buf := make([]byte, 32)
+	if _, err := rw.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return err
+	}
+
+	h.writeTo(buf)
+	_, err := rw.WriteAt(buf, 0)
+	return err
+}
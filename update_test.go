@@ -0,0 +1,122 @@
+package dbf3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// expectSynced checks that file on disk equals to saved file
+func expectSynced(t *testing.T, f File, fileName string) {
+	t.Helper()
+	disk, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved := saveTestFile(t, f); !bytes.Equal(saved, disk) {
+		t.Fatalf("file on disk (%d bytes) differs from saved one (%d bytes)",
+			len(disk), len(saved))
+	}
+}
+
+func TestOpenUpdate(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 50).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, step := range []struct {
+		name   string
+		change func() error
+	}{
+		{"set", func() error {
+			for _, idx := range []int{3, 4, 40} {
+				if err := f.Set(idx, "NAME", "changed"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"new row", func() error {
+			idx, err := f.NewRow()
+			if err != nil {
+				return err
+			}
+			return f.Set(idx, "NAME", "new")
+		}},
+		{"pack", func() error {
+			if err := f.DelRow(0); err != nil {
+				return err
+			}
+			return f.Pack()
+		}},
+		{"add field", func() error {
+			if err := f.AddField("FLAG", Logical, 0, 0); err != nil {
+				return err
+			}
+			return f.Set(0, "FLAG", "T")
+		}},
+		{"delete field", func() error {
+			return f.DelField("FLAG")
+		}},
+		{"language", func() error {
+			f.SetLang(Lang201)
+			return nil
+		}},
+	} {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if err := f.Flush(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		expectSynced(t, f, fileName)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Rows() != 50 || g.Lang() != Lang201 || len(g.Fields()) != 2 {
+		t.Fatalf("unexpected file: %d rows, language %d, %d fields",
+			g.Rows(), g.Lang(), len(g.Fields()))
+	}
+	expectValue(t, g, 2, "NAME", "changed")
+	expectValue(t, g, 49, "NAME", "new")
+}
+
+func TestOpenUpdateClose(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 3).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenUpdate(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(1, "AMOUNT", "7"); err != nil {
+		t.Fatal(err)
+	}
+	// changes are flushed by Close
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, g, 1, "AMOUNT", "7")
+}
@@ -0,0 +1,72 @@
+package dbf3
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// dateLayout presents layout of Date field values
+const dateLayout = "20060102"
+
+// parseValue converts raw field value into typed one:
+// string for Character, float64 for Numeric,
+// time.Time for Date (zero time for blank date)
+// and bool for Logical fields
+func parseValue(typ FieldType, s string) (interface{}, error) {
+	switch typ {
+	case Numeric:
+		if s == "" {
+			return float64(0), nil
+		}
+		if !validNumeric(s) {
+			return nil, errors.New("invalid numeric value")
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.New("invalid numeric value")
+		}
+		return n, nil
+	case Date:
+		if s == "" {
+			return time.Time{}, nil
+		}
+		d, err := time.ParseInLocation(dateLayout, s, time.Local)
+		if err != nil {
+			return nil, errors.New("invalid date value")
+		}
+		return d, nil
+	case Logical:
+		switch s {
+		case "T", "t", "Y", "y":
+			return true, nil
+		case "F", "f", "N", "n", "?", "":
+			return false, nil
+		default:
+			return nil, errors.New("invalid logical value")
+		}
+	default:
+		return s, nil
+	}
+}
+
+// validNumeric checks syntax of Numeric field value:
+// optional sign, digits and optional decimal point
+func validNumeric(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+
+	var digits, points int
+	for idx := 0; idx < len(s); idx++ {
+		switch {
+		case isDigit(s[idx]):
+			digits++
+		case s[idx] == '.':
+			points++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && points <= 1
+}
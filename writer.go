@@ -0,0 +1,297 @@
+package dbf3
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+type writer struct {
+	header    header
+	fields    []*field
+	converter TextConverter
+
+	w      *bufio.Writer
+	ws     io.WriteSeeker // nil if writer is not seekable
+	start  int64          // offset of the header
+	rows   int            // expected rows count (-1 if unknown)
+	count  int            // rows count
+	row    []byte
+	closer io.Closer // underlying file (if opened by name)
+	lock   *locker   // lock of appended file (nil if not locked)
+}
+
+// NewWriter creates DBF stream writer with specified fields
+// and writes header. If w is not io.WriteSeeker,
+// rows count must be specified by WithRows option
+func NewWriter(w io.Writer, fields []Field, opts ...Option) (Writer, error) {
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if int64(o.rows) > math.MaxUint32 {
+		return nil, errors.New("exceeded max rows count")
+	}
+
+	wr := &writer{
+		header:    newHeader(o.lang),
+		converter: o.convCtor(o.lang),
+		w:         bufio.NewWriter(w),
+		rows:      o.rows,
+	}
+
+	if ws, ok := w.(io.WriteSeeker); ok {
+		start, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		wr.ws, wr.start = ws, start
+	} else if o.rows < 0 {
+		return nil, errors.New("rows count must be specified for not seekable writer")
+	}
+
+	if len(fields) > maxFields {
+		return nil, errors.New("exceeded max fields count")
+	}
+
+	names := make(map[string]bool)
+	offset := 1 // fields starts after deletion flag
+	for idx := range fields {
+		dt, err := descrOf(fields[idx])
+		if err != nil {
+			return nil, err
+		}
+		fld, err := newField(dt, idx, offset)
+		if err != nil {
+			return nil, err
+		}
+		if names[fld.Name()] {
+			return nil, errors.New("field already exists")
+		}
+		names[fld.Name()] = true
+		wr.fields = append(wr.fields, fld)
+		offset += fld.Len()
+	}
+
+	wr.header.hlen = uint16(32 + len(fields)*32 + 1)
+	wr.header.rlen = uint16(offset)
+	if o.rows > 0 {
+		wr.header.rows = uint32(o.rows)
+	}
+	wr.row = make([]byte, offset)
+
+	if err := wr.writeHead(); err != nil {
+		return nil, err
+	}
+
+	return wr, nil
+}
+
+// OpenAppend opens existing DBF file for appending rows.
+// Rows are written after the last row of the file,
+// rows count and date of last change are updated by Close.
+// Header of the file is locked until Close (see WithLockScheme),
+// so other appenders wait for it
+func OpenAppend(fileName string, opts ...Option) (Writer, error) {
+	if err := recoverJournal(fileName, false); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// rows count must be read under lock
+	lock := newLocker(fd, o)
+	if lock != nil {
+		if err := lock.lockRecord(-1); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+
+	w, err := newAppender(fd, opts...)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	w.closer = fd
+	w.lock = lock
+	return w, nil
+}
+
+func newAppender(fd *os.File, opts ...Option) (*writer, error) {
+	hdr, fields, _, err := readHead(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.applyLang(&hdr)
+
+	// check fields layout
+	rlen := 1
+	for _, fld := range fields {
+		if fld.offset != rlen {
+			return nil, errors.New("fields layout does not match row length")
+		}
+		rlen += fld.Len()
+	}
+	if rlen != int(hdr.rlen) {
+		return nil, errors.New("fields layout does not match row length")
+	}
+
+	st, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := int64(hdr.hlen) + int64(hdr.rows)*int64(hdr.rlen)
+	if st.Size() < end {
+		return nil, errors.New("unexpected end of file")
+	}
+	if _, err := fd.Seek(end, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		header:    hdr,
+		fields:    fields,
+		converter: o.convCtor(LangID(hdr.lang)),
+		w:         bufio.NewWriter(fd),
+		ws:        fd,
+		rows:      -1,
+		count:     int(hdr.rows),
+		row:       make([]byte, rlen),
+	}, nil
+}
+
+func (w *writer) writeHead() error {
+	buf := make([]byte, w.header.hlen)
+
+	// header
+	w.header.writeTo(buf)
+
+	// fields
+	for idx := range w.fields {
+		w.fields[idx].descr.writeTo(buf[32+idx*32:])
+	}
+
+	// header block terminator
+	buf[len(buf)-1] = hterm
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func (w *writer) Fields() []Field {
+	fields := make([]Field, len(w.fields))
+
+	for idx := range w.fields {
+		fields[idx] = w.fields[idx]
+	}
+
+	return fields
+}
+
+func (w *writer) Rows() int {
+	return w.count
+}
+
+func (w *writer) WriteRow(values []string) error {
+	if len(values) != len(w.fields) {
+		return errors.New("values count differs from fields count")
+	}
+	if w.rows >= 0 && w.count >= w.rows || int64(w.count) >= math.MaxUint32 {
+		return errors.New("cannot add more rows")
+	}
+
+	w.row[0] = blank
+	for idx, fld := range w.fields {
+		cval, err := w.converter.Encode(values[idx])
+		if err != nil {
+			return err
+		}
+		if len(cval) > fld.Len() {
+			return errors.New("value larger than the field length")
+		}
+		fld.setValue(w.row, cval)
+	}
+
+	if _, err := w.w.Write(w.row); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+func (w *writer) Close() error {
+	err := w.close()
+	if w.lock != nil {
+		if uerr := w.lock.unlock(); err == nil {
+			err = uerr
+		}
+		w.lock = nil
+	}
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+		w.closer = nil
+	}
+	return err
+}
+
+func (w *writer) close() error {
+	if w.rows >= 0 && w.count != w.rows {
+		return errors.New("written rows count differs from specified")
+	}
+
+	if err := w.w.WriteByte(eof); err != nil {
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if w.ws == nil || w.rows >= 0 {
+		// rows count already written
+		return nil
+	}
+
+	end, err := w.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if fd, ok := w.closer.(*os.File); ok {
+		// remove everything after EOF marker of appended file
+		if err := truncate(fd, end); err != nil {
+			return err
+		}
+	}
+
+	// patch date of last change and rows count
+	w.header.rows = uint32(w.count)
+	w.header.updateChanged()
+	buf := make([]byte, 32)
+	w.header.writeTo(buf)
+	if _, err := w.ws.Seek(w.start+1, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.ws.Write(buf[1:8]); err != nil {
+		return err
+	}
+	_, err = w.ws.Seek(end, io.SeekStart)
+	return err
+}
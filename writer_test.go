@@ -0,0 +1,164 @@
+package dbf3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testFields(t *testing.T) []Field {
+	t.Helper()
+	name, err := NewField("NAME", Character, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount, err := NewField("AMOUNT", Numeric, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []Field{name, amount}
+}
+
+func TestWriterSeekable(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	fd, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	w, err := NewWriter(fd, testFields(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range [][]string{{"a", "1.5"}, {"b", "2"}} {
+		if err := w.WriteRow(values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.Rows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", w.Rows())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", f.Rows())
+	}
+	expectValue(t, f, 0, "NAME", "a")
+	expectValue(t, f, 0, "AMOUNT", "1.5")
+	expectValue(t, f, 1, "NAME", "b")
+	if deleted, _ := f.Deleted(1); deleted {
+		t.Fatal("unexpected deleted row")
+	}
+}
+
+func TestWriterNotSeekable(t *testing.T) {
+	var buf bytes.Buffer
+	w := struct{ *bytes.Buffer }{&buf} // hide Seek method
+
+	if _, err := NewWriter(w, testFields(t)); err == nil {
+		t.Fatal("expected error without rows count")
+	}
+
+	wr, err := NewWriter(w, testFields(t), WithRows(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.WriteRow([]string{"a", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.WriteRow([]string{"b", "2"}); err == nil {
+		t.Fatal("expected error for exceeded rows count")
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 1 {
+		t.Fatalf("expected 1 row, got %d", f.Rows())
+	}
+	expectValue(t, f, 0, "NAME", "a")
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(struct{ *bytes.Buffer }{&buf}, testFields(t), WithRows(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, values := range [][]string{
+		{"a"},
+		{"a", "1", "2"},
+		{"too long value", "1"},
+	} {
+		if err := w.WriteRow(values); err == nil {
+			t.Errorf("expected error for %q", values)
+		}
+	}
+	if err := w.WriteRow([]string{"a", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatal("expected error for less rows than specified")
+	}
+
+	fields := testFields(t)
+	if _, err := NewWriter(&buf, append(fields, fields[0]), WithRows(0)); err == nil {
+		t.Fatal("expected error for duplicate field")
+	}
+}
+
+func TestOpenAppend(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.dbf")
+	if err := newTestFile(t, 2).SaveFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenAppend(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Rows() != 2 || len(w.Fields()) != 2 {
+		t.Fatalf("unexpected layout: %d rows, %d fields", w.Rows(), len(w.Fields()))
+	}
+	if err := w.WriteRow([]string{"too long value", "1"}); err == nil {
+		t.Fatal("expected error for too long value")
+	}
+	if err := w.WriteRow([]string{"appended", "12"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Rows() != 3 {
+		t.Fatalf("expected 3 rows, got %d", f.Rows())
+	}
+	expectValue(t, f, 1, "NAME", "row1")
+	expectValue(t, f, 2, "NAME", "appended")
+	expectValue(t, f, 2, "AMOUNT", "12")
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != f.HLen()+3*f.RLen()+1 || data[len(data)-1] != eof {
+		t.Fatalf("unexpected file size %d", len(data))
+	}
+}